@@ -0,0 +1,243 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+/// The archiveFile type holds the members of a tar or zip archive, which are
+/// served as objects of their own (see `open`)
+type archiveFile struct {
+	obj     object
+	names   []string // names of the regular files, in the order of the archive
+	members map[string]archiveMember
+}
+
+/// The archiveMember type locates a member in the archive: stored data is read
+/// from the archive directly, compressed zip members are inflated into memory
+type archiveMember struct {
+	offset int64
+	size   int64
+	file   *zip.File // nil for tar members
+}
+
+// archive is the archive given with `--archive` (nil if not set)
+var archive *archiveFile
+
+/// The openArchive function opens the archive referred to by the given name
+/// (any supported object, e.g. a local file or a S3 path) and lists its
+/// members. Zip archives are recognized by their local file header, all other
+/// archives are read as (uncompressed) tar files
+func openArchive(ctx context.Context, name string) (*archiveFile, error) {
+	obj, err := open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	size, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	a := &archiveFile{obj: obj, members: make(map[string]archiveMember)}
+	magic := make([]byte, 4)
+	if n, _ := obj.ReadAt(magic, 0); n == len(magic) && bytes.Equal(magic, []byte("PK\x03\x04")) {
+		err = a.listZip(size)
+	} else {
+		err = a.listTar(size)
+	}
+	if err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return a, nil
+}
+
+/// The listTar method lists the regular files of a tar archive. The archive is
+/// read through a seekable section, so the data of the members is skipped
+/// instead of downloaded
+func (a *archiveFile) listTar(size int64) error {
+	section := io.NewSectionReader(a.obj, 0, size)
+	tr := tar.NewReader(section)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		offset, err := section.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		a.add(hdr.Name, archiveMember{offset: offset, size: hdr.Size})
+	}
+	return nil
+}
+
+/// The listZip method lists the regular files of a zip archive
+func (a *archiveFile) listZip(size int64) error {
+	zr, err := zip.NewReader(a.obj, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			return err
+		}
+		a.add(f.Name, archiveMember{offset: offset, size: int64(f.CompressedSize64), file: f})
+	}
+	return nil
+}
+
+/// The add method records a member, a later member of the same name replaces
+/// the earlier one (like when extracting the archive)
+func (a *archiveFile) add(name string, m archiveMember) {
+	name = strings.TrimPrefix(name, "./")
+	if _, ok := a.members[name]; !ok {
+		a.names = append(a.names, name)
+	}
+	a.members[name] = m
+}
+
+/// The open method opens the member of the given name. Compressed zip members
+/// have to be inflated completely, since the trailer is read first; members
+/// inflating to more than `--max-value-size` bytes are rejected
+func (a *archiveFile) open(name string) (object, error) {
+	m, ok := a.members[name]
+	if !ok {
+		return nil, fmt.Errorf("no member %q in the archive", name)
+	}
+	if m.file == nil || m.file.Method == zip.Store {
+		return sectionObject{io.NewSectionReader(a.obj, m.offset, m.size)}, nil
+	}
+	tooLarge := fmt.Errorf("member %q inflates to more than the limit of %d bytes (--max-value-size)", name, dashmaxvaluesize)
+	if dashmaxvaluesize > 0 && m.file.UncompressedSize64 > uint64(dashmaxvaluesize) {
+		return nil, tooLarge
+	}
+	r, err := m.file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// The size in the zip headers is not trusted, the limit applies to the
+	// inflated data as well
+
+	var data []byte
+	if dashmaxvaluesize > 0 {
+		data, err = io.ReadAll(io.LimitReader(r, dashmaxvaluesize+1))
+		if err == nil && int64(len(data)) > dashmaxvaluesize {
+			err = tooLarge
+		}
+	} else {
+		data, err = io.ReadAll(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return memObject{bytes.NewReader(data)}, nil
+}
+
+/// The match method returns the members selected by the given patterns (see
+/// `path.Match`), all members with a supported suffix if there are none. A
+/// member matched by several patterns is returned once. Selected members without
+/// a supported suffix and patterns without a match are rejected, other members
+/// are skipped with a warning
+func (a *archiveFile) match(patterns []string) ([]string, error) {
+	var names []string
+	if len(patterns) == 0 {
+		for _, name := range a.names {
+			if hasValidSuffix(name) {
+				names = append(names, name)
+			}
+		}
+		if skipped := len(a.names) - len(names); skipped > 0 {
+			warnf("warning: %d members of the archive are not '.ion.zst' or '.ion' objects and are skipped", skipped)
+		}
+		return names, nil
+	}
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		found := false
+		for _, name := range a.names {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --member %q: %w", pattern, err)
+			}
+			if !ok {
+				continue
+			}
+			if !hasValidSuffix(name) {
+				return nil, fmt.Errorf("member %q is not a '.ion.zst' or '.ion' object", name)
+			}
+			found = true
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no member of the archive matches %q", pattern)
+		}
+	}
+	return names, nil
+}
+
+func (a *archiveFile) Close() error {
+	return a.obj.Close()
+}
+
+/// The dumpArchive function dumps the selected members of the `--archive` (or
+/// checks them with `--check`) in the order of the archive
+func dumpArchive(out io.Writer) error {
+	names, err := archive.match(dashmember)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return errors.New("no '.ion.zst' or '.ion' objects in the archive")
+	}
+	if dashcheck {
+		for _, name := range names {
+			if err := checkObject(name, out); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return nil
+	}
+	return dumpObjects(names, out)
+}
+
+// --
+
+/// The sectionObject type serves a section of another object (e.g. a member of
+/// an archive) as an object
+type sectionObject struct {
+	*io.SectionReader
+}
+
+func (o sectionObject) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(o.SectionReader, 0, o.Size())), nil
+}
+
+func (o sectionObject) Stat() (int64, error) {
+	return o.Size(), nil
+}
+
+func (sectionObject) Close() error {
+	return nil
+}
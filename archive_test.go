@@ -0,0 +1,79 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeZip writes a zip archive holding the given (deflated) members
+func writeZip(t *testing.T, members map[string][]byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range members {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestArchiveInflateLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	a, err := openArchive(context.Background(), writeZip(t, map[string][]byte{"a.ion.zst": data}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	limit := dashmaxvaluesize
+	defer func() { dashmaxvaluesize = limit }()
+
+	for _, dashmaxvaluesize = range []int64{0, 1000} {
+		obj, err := a.open("a.ion.zst")
+		if err != nil {
+			t.Fatalf("limit %d: %v", dashmaxvaluesize, err)
+		}
+		if size, _ := obj.Stat(); size != int64(len(data)) {
+			t.Errorf("limit %d: size = %d, want %d", dashmaxvaluesize, size, len(data))
+		}
+	}
+	dashmaxvaluesize = 999
+	if _, err := a.open("a.ion.zst"); err == nil {
+		t.Error("got no error for a member exceeding the limit")
+	}
+}
+
+func TestArchiveMatchOverlapping(t *testing.T) {
+	a, err := openArchive(context.Background(), writeZip(t, map[string][]byte{
+		"data/a.ion.zst": nil,
+		"data/b.ion.zst": nil,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	names, err := a.match([]string{"data/a.ion.zst", "data/*.ion.zst"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "data/a.ion.zst" || names[1] != "data/b.ion.zst" {
+		t.Errorf("got %q, want each member once", names)
+	}
+}
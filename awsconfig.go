@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+/// The readAWSConfig function parses the AWS config file into its sections
+/// (e.g. `default`, `profile name` or `sso-session name`) holding the settings
+/// of each section
+func readAWSConfig(filename string) (map[string]map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := make(map[string]map[string]string)
+	var section map[string]string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			section = make(map[string]string)
+			sections[name] = section
+		case section != nil:
+			if split := strings.IndexByte(line, '='); split > 0 {
+				section[strings.TrimSpace(line[:split])] = strings.TrimSpace(line[split+1:])
+			}
+		}
+	}
+	return sections, s.Err()
+}
+
+/// The newConfigProvider function returns the credentials provider for AWS SSO
+/// or role assumption profiles of the AWS config file (`$AWS_CONFIG_FILE` or
+/// `~/.aws/config`). It returns nil if the profile uses neither
+func newConfigProvider(home string) (credentials.Provider, error) {
+	filename := os.Getenv("AWS_CONFIG_FILE")
+	if filename == "" {
+		filename = filepath.Join(home, ".aws", "config")
+	}
+	config, err := readAWSConfig(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	name := dashprofile
+	if name == "" {
+		name = os.Getenv("AWS_PROFILE")
+	}
+	if name == "" {
+		name = "default"
+	}
+	profile, ok := config["profile "+name]
+	if !ok && name == "default" {
+		profile = config["default"]
+	}
+
+	switch {
+	case profile["sso_account_id"] != "":
+		p := &ssoProvider{
+			profile:   name,
+			cache:     filepath.Join(home, ".aws", "sso", "cache"),
+			region:    profile["sso_region"],
+			accountID: profile["sso_account_id"],
+			roleName:  profile["sso_role_name"],
+			cacheKey:  profile["sso_start_url"],
+		}
+
+		// Profiles referring to a `sso-session` section share the token of that
+		// session, which is cached under the name of the session
+
+		if session := profile["sso_session"]; session != "" {
+			settings, ok := config["sso-session "+session]
+			if !ok {
+				return nil, fmt.Errorf("AWS profile %q: sso-session %q not found", name, session)
+			}
+			p.region, p.cacheKey = settings["sso_region"], session
+		}
+		return p, nil
+
+	case profile["role_arn"] != "" && profile["source_profile"] != "":
+		source := &credentials.FileAWSCredentials{Filename: credentialsFile(home), Profile: profile["source_profile"]}
+		keys, err := source.Retrieve()
+		if err != nil {
+			return nil, fmt.Errorf("AWS profile %q: source profile %q: %w", name, profile["source_profile"], err)
+		}
+		endpoint := "https://sts.amazonaws.com"
+		if region := profile["region"]; region != "" {
+			endpoint = "https://sts." + region + ".amazonaws.com"
+		}
+		return &credentials.STSAssumeRole{
+			Client:      &http.Client{Transport: http.DefaultTransport},
+			STSEndpoint: endpoint,
+			Options: credentials.STSAssumeRoleOptions{
+				AccessKey:       keys.AccessKeyID,
+				SecretKey:       keys.SecretAccessKey,
+				Location:        profile["region"],
+				RoleARN:         profile["role_arn"],
+				RoleSessionName: "iondump",
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+/// The ssoProvider type retrieves temporary role credentials using the access
+/// token cached by `aws sso login`
+type ssoProvider struct {
+	credentials.Expiry
+
+	profile   string
+	cache     string // directory of the cached tokens (`~/.aws/sso/cache`)
+	region    string
+	accountID string
+	roleName  string
+	cacheKey  string // start URL or session name, its SHA-1 names the cache file
+	warned    bool
+}
+
+/// The Retrieve method returns the role credentials. The credential chain
+/// ignores the errors of its providers (falling back to anonymous requests), so
+/// the first error is reported as a warning
+func (p *ssoProvider) Retrieve() (credentials.Value, error) {
+	creds, err := p.retrieve()
+	if err != nil && !p.warned {
+		p.warned = true
+		warnf("warning: %v", err)
+	}
+	return creds, err
+}
+
+func (p *ssoProvider) retrieve() (credentials.Value, error) {
+	token, err := p.token()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	query := url.Values{"account_id": {p.accountID}, "role_name": {p.roleName}}
+	endpoint := fmt.Sprintf("https://portal.sso.%s.amazonaws.com/federation/credentials?%s", p.region, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	req.Header.Set("x-amz-sso_bearer_token", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return credentials.Value{}, p.expired()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return credentials.Value{}, fmt.Errorf("AWS SSO credentials of profile %q: %s", p.profile, resp.Status)
+	}
+
+	var result struct {
+		RoleCredentials struct {
+			AccessKeyID     string `json:"accessKeyId"`
+			SecretAccessKey string `json:"secretAccessKey"`
+			SessionToken    string `json:"sessionToken"`
+			Expiration      int64  `json:"expiration"` // milliseconds since the epoch
+		} `json:"roleCredentials"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return credentials.Value{}, err
+	}
+	creds := result.RoleCredentials
+	p.SetExpiration(time.UnixMilli(creds.Expiration), credentials.DefaultExpiryWindow)
+	return credentials.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}, nil
+}
+
+/// The token method returns the cached access token of the SSO session
+func (p *ssoProvider) token() (string, error) {
+	sum := sha1.Sum([]byte(p.cacheKey))
+	data, err := os.ReadFile(filepath.Join(p.cache, hex.EncodeToString(sum[:])+".json"))
+	if os.IsNotExist(err) {
+		return "", p.expired()
+	} else if err != nil {
+		return "", err
+	}
+
+	var cached struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresAt   string `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return "", fmt.Errorf("AWS SSO token cache of profile %q: %w", p.profile, err)
+	}
+
+	// Older versions of the AWS CLI write the expiration as `...T12:00:00UTC`
+
+	expires, err := time.Parse(time.RFC3339, cached.ExpiresAt)
+	if err != nil {
+		expires, err = time.Parse("2006-01-02T15:04:05UTC", cached.ExpiresAt)
+	}
+	if err != nil || cached.AccessToken == "" || time.Now().After(expires) {
+		return "", p.expired()
+	}
+	return cached.AccessToken, nil
+}
+
+func (p *ssoProvider) expired() error {
+	return fmt.Errorf("the AWS SSO session of profile %q has expired or is missing, run 'aws sso login --profile %s'", p.profile, p.profile)
+}
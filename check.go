@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/// The modTimer interface is implemented by objects which know the time of
+/// their last modification
+type modTimer interface {
+	ModTime() time.Time
+}
+
+/// The check function verifies that the object referred to by the given name
+/// (or every object below a S3 prefix) exists, has a supported suffix and can be
+/// accessed, without reading its content. The size and the time of the last
+/// modification of every object are written to the output stream
+func check(name string, out io.Writer) error {
+	if !isS3Prefix(name) {
+		return checkObject(name, out)
+	}
+	names, err := listS3(ctx, s3Endpoint(), name, dashrecursive)
+	if err != nil {
+		return withStage("open", err)
+	}
+	for _, name := range names {
+		if err := checkObject(name, out); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+/// The checkObject function checks a single object. Only the metadata of the
+/// object is requested (e.g. using `StatObject`); plain HTTP(S) URLs are never
+/// downloaded, even if the server does not support `Range` requests
+func checkObject(name string, out io.Writer) error {
+	if name == "-" {
+		return errors.New("--check cannot be used with stdin")
+	}
+	if !hasValidSuffix(objectPath(name)) {
+		return errors.New("no valid '.ion.zst' or '.ion' object specified")
+	}
+
+	var (
+		obj object
+		err error
+	)
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		obj, err = openHTTP(ctx, http.DefaultClient, name, nil)
+	} else {
+		obj, err = open(ctx, name)
+	}
+	if err != nil {
+		return withStage("open", err)
+	}
+	defer obj.Close()
+
+	size, err := obj.Stat()
+	if err != nil {
+		return withStage("open", err)
+	}
+	modified := "unknown"
+	if m, ok := obj.(modTimer); ok && !m.ModTime().IsZero() {
+		modified = m.ModTime().UTC().Format(time.RFC3339)
+	}
+	_, err = fmt.Fprintf(out, "%s: %d bytes, last modified %s\n", redactURL(name), size, modified)
+	return err
+}
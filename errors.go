@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"iondump/ionzst"
+)
+
+/// The stageError type records the processing stage an error occurred in (e.g.
+/// `extract` or `dump`)
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stageError) Unwrap() error {
+	return e.err
+}
+
+/// The withStage function attaches the stage to the error. Errors which already
+/// carry a stage (e.g. passed downstream through a pipe) keep it
+func withStage(stage string, err error) error {
+	var serr *stageError
+	if err == nil || err == io.ErrClosedPipe || errors.As(err, &serr) {
+		return err
+	}
+	return &stageError{stage: stage, err: err}
+}
+
+/// The jsonError type is the `--json-errors` representation of an error
+type jsonError struct {
+	Error string `json:"error"`
+	Stage string `json:"stage,omitempty"`
+	Chunk *int   `json:"chunk,omitempty"`
+}
+
+/// The printJSONError function writes the error as a single JSON object to
+/// stderr, using the given message
+func printJSONError(msg string, err error) {
+	out := jsonError{Error: msg}
+	var serr *stageError
+	if errors.As(err, &serr) {
+		out.Stage = serr.stage
+	}
+	var cerr *ionzst.ChunkError
+	if errors.As(err, &cerr) {
+		out.Chunk = &cerr.Chunk
+	}
+	json.NewEncoder(os.Stderr).Encode(out)
+}
+
+/// The stageWriter type attaches the stage to the errors of the wrapped writer
+type stageWriter struct {
+	stage string
+	w     io.Writer
+}
+
+func (w *stageWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	return n, withStage(w.stage, err)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+/// The follow function dumps the object and then polls it every `--interval`
+/// until interrupted (or the `--timeout` expires). Whenever the body (re-read
+/// using the trailer) has grown, only the chunks appended since the previous
+/// poll are dumped, so appended chunks have to start with a symbol table of
+/// their own (as written by Sneller)
+func follow(name string, out io.Writer) error {
+	for {
+		if err := dumpObject(name, out); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		// The output is flushed after every poll, it may be a file watched by
+		// another process
+
+		if f, ok := out.(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+
+		timer := time.NewTimer(dashinterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
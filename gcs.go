@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/// The openGCS function opens a Google Cloud Storage object (`gs://bucket/object`).
+/// The requests are authorized using the Application Default Credentials (see
+/// gcsTokenSource), the object is accessed anonymously if there are none or
+/// with `--anonymous`
+func openGCS(ctx context.Context, name string) (object, error) {
+	path := strings.TrimPrefix(name, "gs://")
+	split := strings.IndexByte(path, '/')
+	if split <= 0 || split == len(path)-1 {
+		return nil, fmt.Errorf("invalid gs path spec %q", path)
+	}
+
+	gcsOnce.Do(func() {
+		gcsSource, gcsErr = gcsTokenSource(ctx, http.DefaultClient)
+	})
+	if gcsErr != nil {
+		return nil, gcsErr
+	}
+	client := http.DefaultClient
+	if gcsSource != nil {
+		client = &http.Client{Transport: &tokenTransport{base: http.DefaultTransport, source: gcsSource}}
+	}
+
+	u := url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + path}
+	return openHTTP(ctx, client, u.String(), nil)
+}
+
+// gcsSource provides the access tokens of all GCS requests (nil = anonymous),
+// it is set up once by the first `gs://` object
+var (
+	gcsOnce   sync.Once
+	gcsSource *tokenSource
+	gcsErr    error
+)
+
+/// The gcsScope constant is the OAuth2 scope of the access tokens
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+/// The tokenRefreshMargin constant is the time before the expiry of an access
+/// token at which it is replaced by a new one
+const tokenRefreshMargin = 5 * time.Minute
+
+/// The gcsTokenSource function returns the token source of the Application
+/// Default Credentials, which are looked up in this order: the key file
+/// `GOOGLE_APPLICATION_CREDENTIALS` points to, the file written by `gcloud auth
+/// application-default login` and the metadata server of Google Cloud
+/// instances. Key files may hold a service account key or user credentials. If
+/// no credentials are found, a warning is logged and nil is returned
+func gcsTokenSource(ctx context.Context, client *http.Client) (*tokenSource, error) {
+	if dashanonymous {
+		return nil, nil
+	}
+	keyfile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if keyfile == "" {
+		if file := gcloudCredentialsFile(); file != "" {
+			if _, err := os.Stat(file); err == nil {
+				keyfile = file
+			}
+		}
+	}
+	if keyfile != "" {
+		return keyFileTokenSource(client, keyfile)
+	}
+	if host := metadataHost(ctx, client); host != "" {
+		return &tokenSource{fetch: func(ctx context.Context) (string, time.Time, error) {
+			return metadataToken(ctx, client, host)
+		}}, nil
+	}
+	warnf("warning: no Google credentials found (GOOGLE_APPLICATION_CREDENTIALS, %s or the metadata server), accessing GCS anonymously", gcloudCredentialsFile())
+	return nil, nil
+}
+
+/// The gcloudCredentialsFile function returns the path of the credentials file
+/// written by `gcloud auth application-default login`
+func gcloudCredentialsFile() string {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, "application_default_credentials.json")
+	}
+	if dir := os.Getenv("APPDATA"); dir != "" {
+		return filepath.Join(dir, "gcloud", "application_default_credentials.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+/// The googleCredentials type holds the relevant parts of a key file: either a
+/// service account key or user credentials (`authorized_user`)
+type googleCredentials struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+/// The keyFileTokenSource function returns the token source of the given key
+/// file
+func keyFileTokenSource(client *http.Client, keyfile string) (*tokenSource, error) {
+	data, err := os.ReadFile(keyfile)
+	if err != nil {
+		return nil, err
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("%s: %w", keyfile, err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	var fetch func(ctx context.Context) (string, time.Time, error)
+	switch creds.Type {
+	case "service_account":
+		key, err := parseRSAKey(creds.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyfile, err)
+		}
+		fetch = func(ctx context.Context) (string, time.Time, error) {
+			return serviceAccountToken(ctx, client, creds, key)
+		}
+	case "authorized_user":
+		fetch = func(ctx context.Context) (string, time.Time, error) {
+			return requestToken(ctx, client, creds.TokenURI, url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {creds.ClientID},
+				"client_secret": {creds.ClientSecret},
+				"refresh_token": {creds.RefreshToken},
+			})
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported credentials type %q", keyfile, creds.Type)
+	}
+	return &tokenSource{fetch: fetch}, nil
+}
+
+/// The parseRSAKey function parses the PEM encoded private key of a service
+/// account
+func parseRSAKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not a RSA key")
+	}
+	return key, nil
+}
+
+/// The serviceAccountToken function exchanges a signed JWT for a read-only
+/// access token of the service account
+func serviceAccountToken(ctx context.Context, client *http.Client, creds googleCredentials, key *rsa.PrivateKey) (string, time.Time, error) {
+
+	// The JWT is signed using RS256 and is valid for one hour
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": gcsScope,
+		"aud":   creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return requestToken(ctx, client, creds.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + enc.EncodeToString(sig)},
+	})
+}
+
+/// The tokenResponse type is the response of the token endpoints and of the
+/// metadata server
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+/// The requestToken function posts the form to the OAuth2 token endpoint and
+/// returns the access token and its expiry
+func requestToken(ctx context.Context, client *http.Client, tokenURI string, form url.Values) (string, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doTokenRequest(client, req)
+}
+
+/// The metadataHost function returns the host of the metadata server, or an
+/// empty string if there is none. `GCE_METADATA_HOST` overrides the default
+/// host, which is only probed on Google Cloud instances (as told by the DMI
+/// product name), so that no request leaves for 169.254.169.254 elsewhere
+func metadataHost(ctx context.Context, client *http.Client) string {
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		return host
+	}
+	if !onGoogleCloud() {
+		return ""
+	}
+	const host = "169.254.169.254"
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/", nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := client.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Metadata-Flavor") != "Google" {
+		return ""
+	}
+	return host
+}
+
+/// The onGoogleCloud function reports whether the DMI product name is the one
+/// of Google Compute Engine instances ("Google Compute Engine")
+func onGoogleCloud() bool {
+	name, err := os.ReadFile("/sys/class/dmi/id/product_name")
+	return err == nil && strings.Contains(string(name), "Google")
+}
+
+/// The metadataToken function requests an access token of the default service
+/// account of the instance from the metadata server
+func metadataToken(ctx context.Context, client *http.Client, host string) (string, time.Time, error) {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     host,
+		Path:     "/computeMetadata/v1/instance/service-accounts/default/token",
+		RawQuery: url.Values{"scopes": {gcsScope}}.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return doTokenRequest(client, req)
+}
+
+/// The doTokenRequest function sends the token request and decodes the token
+/// response
+func doTokenRequest(client *http.Client, req *http.Request) (string, time.Time, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("%s: %s", req.URL.Host+req.URL.Path, resp.Status)
+	}
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", time.Time{}, err
+	}
+	if token.AccessToken == "" {
+		return "", time.Time{}, errors.New("no access token received")
+	}
+	return token.AccessToken, time.Now().Add(time.Duration(token.ExpiresIn) * time.Second), nil
+}
+
+// --
+
+/// The tokenSource type caches an access token and fetches a new one once the
+/// token is about to expire, so that long running dumps (e.g. `--follow`) keep
+/// their access. It is safe for concurrent use
+type tokenSource struct {
+	fetch func(ctx context.Context) (string, time.Time, error)
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+/// The Token method returns a valid access token
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expiry) > tokenRefreshMargin {
+		return s.token, nil
+	}
+	token, expiry, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiry = token, expiry
+	return token, nil
+}
+
+/// The tokenTransport type authorizes every request with an access token of the
+/// token source
+type tokenTransport struct {
+	base   http.RoundTripper
+	source *tokenSource
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
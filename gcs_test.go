@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenSourceRefresh(t *testing.T) {
+	fetched := 0
+	expiry := time.Now().Add(time.Hour)
+	s := &tokenSource{fetch: func(ctx context.Context) (string, time.Time, error) {
+		fetched++
+		return fmt.Sprintf("token%d", fetched), expiry, nil
+	}}
+
+	for i, want := range []string{"token1", "token1"} {
+		if got, err := s.Token(context.Background()); err != nil || got != want {
+			t.Fatalf("call %d: got %q, %v, want %q", i, got, err, want)
+		}
+	}
+
+	// A token about to expire is replaced
+
+	expiry = time.Now().Add(time.Minute)
+	s.expiry = expiry
+	if got, err := s.Token(context.Background()); err != nil || got != "token2" {
+		t.Fatalf("got %q, %v, want token2", got, err)
+	}
+}
+
+func TestAuthorizedUserCredentials(t *testing.T) {
+	tokens := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh" {
+			http.Error(w, "invalid grant", http.StatusBadRequest)
+			return
+		}
+		tokens++
+		fmt.Fprintf(w, `{"access_token":"access%d","expires_in":3600}`, tokens)
+	}))
+	defer tokenServer.Close()
+
+	var authorization string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer storage.Close()
+
+	keyfile := filepath.Join(t.TempDir(), "credentials.json")
+	data := fmt.Sprintf(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"refresh","token_uri":%q}`, tokenServer.URL)
+	if err := os.WriteFile(keyfile, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := keyFileTokenSource(http.DefaultClient, keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &tokenTransport{base: http.DefaultTransport, source: source}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(storage.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if authorization != "Bearer access1" {
+			t.Errorf("request %d: Authorization = %q, want %q", i, authorization, "Bearer access1")
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("%d tokens requested, want 1", tokens)
+	}
+}
+
+func TestUnsupportedCredentials(t *testing.T) {
+	keyfile := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(keyfile, []byte(`{"type":"external_account"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyFileTokenSource(http.DefaultClient, keyfile); err == nil {
+		t.Error("got no error for an unsupported credentials type")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/// The httpObject type serves an object over HTTP(S) using `Range` requests
+type httpObject struct {
+	client *http.Client
+	url    string
+	header http.Header // additional request headers (e.g. authorization)
+	size   int64
+	ranges bool      // server announced support for `Range` requests
+	mtime  time.Time // `Last-Modified` of the object (zero if unknown)
+
+	ctx context.Context // context of random access reads
+}
+
+/// The openHTTP function determines the size of the object at the given URL
+func openHTTP(ctx context.Context, client *http.Client, url string, header http.Header) (object, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	copyHeader(req.Header, header)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	// Presigned URLs are only valid for the signed method (usually GET), so the
+	// size is requested along with the first byte instead
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusMethodNotAllowed:
+		return probeHTTP(ctx, client, url, header)
+	default:
+		return nil, fmt.Errorf("%s: %s", redactURL(url), resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("%s: unknown object size", redactURL(url))
+	}
+	ranges := resp.Header.Get("Accept-Ranges") == "bytes"
+	mtime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &httpObject{client: client, url: url, header: header, size: resp.ContentLength, ranges: ranges, mtime: mtime, ctx: ctx}, nil
+}
+
+/// The probeHTTP function determines the size of the object at the given URL
+/// using a `Range` request of the first byte, the size is taken from the
+/// `Content-Range` header of the response (e.g. `bytes 0-0/1234`)
+func probeHTTP(ctx context.Context, client *http.Client, url string, header http.Header) (object, error) {
+	o := &httpObject{client: client, url: url, header: header, ranges: true, ctx: ctx}
+	body, contentRange, err := o.request(ctx, "bytes=0-0")
+	if err != nil {
+		return nil, err
+	}
+	body.Close()
+	size := contentRange[strings.LastIndexByte(contentRange, '/')+1:]
+	if o.size, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return nil, fmt.Errorf("%s: unknown object size (Content-Range %q)", redactURL(url), contentRange)
+	}
+	return o, nil
+}
+
+/// The openURL function opens an object served by a plain HTTP(S) server. If the
+/// server does not support `Range` requests, the whole object is buffered in
+/// memory, since the trailer is located at the end of the object
+func openURL(ctx context.Context, url string) (object, error) {
+	obj, err := openHTTP(ctx, http.DefaultClient, url, nil)
+	if err == nil {
+		if o := obj.(*httpObject); o.ranges {
+			return o, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", redactURL(url), resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return memObject{bytes.NewReader(data)}, nil
+}
+
+/// The get method requests the bytes from `from` up to and including `to` (or
+/// up to the end of the object if `to` is negative)
+func (o *httpObject) get(ctx context.Context, from, to int64) (io.ReadCloser, error) {
+	byteRange := fmt.Sprintf("bytes=%d-%d", from, to)
+	if to < 0 {
+		byteRange = fmt.Sprintf("bytes=%d-", from)
+	}
+	body, _, err := o.request(ctx, byteRange)
+	return body, err
+}
+
+/// The request method sends a GET request for the given byte range, using the
+/// URL unchanged (including the query parameters of presigned URLs). It returns
+/// the body and the `Content-Range` header of the response
+func (o *httpObject) request(ctx context.Context, byteRange string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	copyHeader(req.Header, o.header)
+	req.Header.Set("Range", byteRange)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, "", &statusError{url: redactURL(o.url), status: resp.Status, code: resp.StatusCode}
+	}
+	return resp.Body, resp.Header.Get("Content-Range"), nil
+}
+
+func (o *httpObject) Open(ctx context.Context) (io.ReadCloser, error) {
+	return o.get(ctx, 0, -1)
+}
+
+func (o *httpObject) openAt(ctx context.Context, off int64) (io.ReadCloser, error) {
+	return o.get(ctx, off, -1)
+}
+
+func (o *httpObject) Stat() (int64, error) {
+	return o.size, nil
+}
+
+func (o *httpObject) ModTime() time.Time {
+	return o.mtime
+}
+
+func (o *httpObject) ReadAt(p []byte, off int64) (int, error) {
+	if off >= o.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > o.size {
+		end = o.size
+	}
+	body, err := o.get(o.ctx, off, end-1)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:end-off])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (o *httpObject) Close() error {
+	return nil
+}
+
+/// The statusError type reports an unexpected HTTP response status
+type statusError struct {
+	url    string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.url, e.status)
+}
+
+/// The redactURL function removes the query from the URL for error messages,
+/// since the query of a presigned URL holds its credentials
+func redactURL(raw string) string {
+	if i := strings.IndexByte(raw, '?'); i >= 0 {
+		return raw[:i] + "?..."
+	}
+	return raw
+}
+
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		dst[key] = values
+	}
+}
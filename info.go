@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+/// The counter type counts the writes and bytes passed to the underlying writer.
+/// Since `extract` writes every chunk at once, the number of writes equals the
+/// number of chunks. The counts are updated atomically, since `--progress`
+/// reads them concurrently
+type counter struct {
+	w      io.Writer
+	writes int64
+	bytes  int64
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(&c.writes, 1)
+	atomic.AddInt64(&c.bytes, int64(n))
+	return n, err
+}
+
+/// The sizeRecorder type records the size of every write passed to the
+/// underlying writer, i.e. the size of every chunk written at once
+type sizeRecorder struct {
+	w     io.Writer
+	sizes []int64
+}
+
+func (r *sizeRecorder) Write(p []byte) (int, error) {
+	r.sizes = append(r.sizes, int64(len(p)))
+	return r.w.Write(p)
+}
+
+/// The printSizes function writes the table printed by `--count-bytes`: the
+/// compressed and decompressed size and the compression ratio of every chunk
+/// (numbered starting at `first`), followed by the totals
+func printSizes(out io.Writer, first int, bodySize int64, compressed, decompressed []int64) error {
+	if _, err := fmt.Fprintf(out, "%8s %14s %14s %8s\n", "chunk", "compressed", "decompressed", "ratio"); err != nil {
+		return err
+	}
+	var totalCompressed, totalDecompressed int64
+	for i, c := range compressed {
+		var d int64
+		if i < len(decompressed) {
+			d = decompressed[i]
+		}
+		totalCompressed += c
+		totalDecompressed += d
+		if _, err := fmt.Fprintf(out, "%8d %14d %14d %8s\n", first+i, c, d, ratio(c, d)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(out, "%8s %14d %14d %8s\n", "total", totalCompressed, totalDecompressed, ratio(totalCompressed, totalDecompressed)); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\n%d chunks, size without trailer %d bytes (including the blob headers)\n", len(compressed), bodySize)
+	return err
+}
+
+/// The ratio function formats the compression ratio (decompressed size divided
+/// by compressed size)
+func ratio(compressed, decompressed int64) string {
+	if compressed == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", float64(decompressed)/float64(compressed))
+}
+
+/// The info type holds the summary printed by `--info`
+type info struct {
+	size         int64 // size of the object
+	bodySize     int64 // size of the object excluding the trailer
+	chunks       int64 // number of ION blob chunks
+	decompressed int64 // total number of decompressed bytes
+}
+
+/// The print function writes the summary as aligned key/value lines
+func (i *info) print(out io.Writer) {
+	fmt.Fprintf(out, "%-22s %d\n", "object size:", i.size)
+	fmt.Fprintf(out, "%-22s %d\n", "trailer offset:", i.size-i.bodySize-4)
+	fmt.Fprintf(out, "%-22s %d\n", "size without trailer:", i.bodySize)
+	fmt.Fprintf(out, "%-22s %d\n", "chunks:", i.chunks)
+	fmt.Fprintf(out, "%-22s %d\n", "decompressed bytes:", i.decompressed)
+}
@@ -0,0 +1,52 @@
+package ionzst
+
+import (
+	"io"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The dumpBinary function copies the values of the input into a single binary
+/// ION stream with one symbol table covering all values, which can be loaded as
+/// a standalone `.10n` file. Unlike the `ion` format the values are not decoded
+/// into Go values, so annotations and all ION types are preserved. The binary
+/// writer only produces output once all values are written, so the output is
+/// buffered in memory. With local symbol tables (see DumpOptions.Symbols) every
+/// value is written as soon as it is copied instead. Only Skip and Limit are
+/// applied
+func dumpBinary(in io.Reader, out io.Writer, opts DumpOptions) error {
+	local := opts.Symbols == "local"
+	r := ion.NewReader(in)
+	w := ion.NewBinaryWriter(out)
+	for n := 0; r.Next(); n++ {
+		if opts.Limit > 0 && n >= opts.Skip+opts.Limit {
+			break
+		}
+		opts.decoded()
+		if n < opts.Skip {
+			continue
+		}
+		if local {
+
+			// Every writer starts its output with a BVM and a local symbol table
+			// holding only the symbols of its value
+
+			w = ion.NewBinaryWriter(out)
+		}
+		if err := copyValue(r, w); err != nil {
+			return err
+		}
+		if local {
+			if err := w.Finish(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if local {
+		return nil
+	}
+	return w.Finish()
+}
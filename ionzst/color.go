@@ -0,0 +1,216 @@
+package ionzst
+
+import (
+	"bytes"
+	"io"
+)
+
+/// The ANSI escape sequences used for the token classes of ION text
+const (
+	colorReset      = "\x1b[0m"
+	colorField      = "\x1b[1;34m" // field names
+	colorString     = "\x1b[32m"   // strings, clobs and blobs
+	colorNumber     = "\x1b[33m"   // numbers and timestamps
+	colorSymbol     = "\x1b[36m"   // symbols
+	colorKeyword    = "\x1b[35m"   // null, booleans, nan and infinities
+	colorAnnotation = "\x1b[2m"    // annotations
+	colorComment    = "\x1b[90m"   // comment lines (e.g. of `Hex`)
+)
+
+/// The colorWriter type highlights ION text written by the text encoder using
+/// ANSI escape sequences. The encoder never breaks a token across lines, so the
+/// output is colorized line by line; incomplete lines are kept until they are
+/// completed or Flush is called
+type colorWriter struct {
+	out  io.Writer
+	line []byte
+	buf  []byte
+}
+
+func newColorWriter(out io.Writer) *colorWriter {
+	return &colorWriter{out: out}
+}
+
+func (w *colorWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	end := bytes.LastIndexByte(w.line, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	if err := w.write(w.line[:end+1]); err != nil {
+		return 0, err
+	}
+	w.line = append(w.line[:0], w.line[end+1:]...)
+	return len(p), nil
+}
+
+/// The Flush method writes the remaining incomplete line and flushes the
+/// output if it is buffered
+func (w *colorWriter) Flush() error {
+	if len(w.line) > 0 {
+		err := w.write(w.line)
+		w.line = w.line[:0]
+		if err != nil {
+			return err
+		}
+	}
+	if f, ok := w.out.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+/// The write method colorizes complete lines and writes them to the output
+func (w *colorWriter) write(text []byte) error {
+	w.buf = w.buf[:0]
+	for len(text) > 0 {
+		end := bytes.IndexByte(text, '\n') + 1
+		if end == 0 {
+			end = len(text)
+		}
+		w.buf = colorize(w.buf, text[:end])
+		text = text[end:]
+	}
+	_, err := w.out.Write(w.buf)
+	return err
+}
+
+/// The colorize function appends the colorized line to dst
+func colorize(dst, line []byte) []byte {
+	if bytes.HasPrefix(line, []byte("#")) {
+		body := bytes.TrimRight(line, "\n")
+		dst = append(append(append(dst, colorComment...), body...), colorReset...)
+		return append(dst, line[len(body):]...)
+	}
+
+	for i := 0; i < len(line); {
+		c := line[i]
+		var end int
+		var color string
+		switch {
+		case bytes.HasPrefix(line[i:], []byte("'''")):
+			end, color = scanLongString(line, i), colorString
+		case c == '"':
+			end, color = scanQuoted(line, i, '"'), colorString
+		case c == '\'':
+			end, color = scanQuoted(line, i, '\''), colorSymbol
+		case bytes.HasPrefix(line[i:], []byte("{{")):
+			end, color = scanLob(line, i), colorString
+		case isDigit(c) || (c == '-' || c == '+') && i+1 < len(line) && (isDigit(line[i+1]) || line[i+1] == 'i'):
+			end, color = scanNumber(line, i), colorNumber
+			if string(line[i:end]) == "+inf" || string(line[i:end]) == "-inf" {
+				color = colorKeyword
+			}
+		case isIdentifierStart(c):
+			end, color = scanIdentifier(line, i), colorSymbol
+			switch string(line[i:end]) {
+			case "null", "true", "false", "nan":
+				color = colorKeyword
+			}
+		default:
+			dst = append(dst, c)
+			i++
+			continue
+		}
+
+		// Symbols and strings followed by `::` are annotations, followed by a
+		// single `:` they are field names
+
+		next := line[end:]
+		switch {
+		case bytes.HasPrefix(next, []byte("::")):
+			color = colorAnnotation
+		case bytes.HasPrefix(next, []byte(":")) && color != colorNumber:
+			color = colorField
+		}
+		dst = append(append(append(dst, color...), line[i:end]...), colorReset...)
+		i = end
+	}
+	return dst
+}
+
+/// The scanQuoted function returns the end of the string or quoted symbol
+/// starting at `start`
+func scanQuoted(line []byte, start int, quote byte) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(line)
+}
+
+/// The scanLongString function returns the end of the long string starting at
+/// `start`, including adjacent long strings (which are concatenated)
+func scanLongString(line []byte, start int) int {
+	i := start
+	for bytes.HasPrefix(line[i:], []byte("'''")) {
+		i += 3
+		for i < len(line) && !bytes.HasPrefix(line[i:], []byte("'''")) {
+			if line[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(line) {
+			return len(line)
+		}
+		i += 3
+	}
+	return i
+}
+
+/// The scanLob function returns the end of the blob or clob starting at `start`
+func scanLob(line []byte, start int) int {
+	i := start + 2
+	for i < len(line) && !bytes.HasPrefix(line[i:], []byte("}}")) {
+		if line[i] == '"' {
+			i = scanQuoted(line, i, '"')
+			continue
+		}
+		i++
+	}
+	if i >= len(line) {
+		return len(line)
+	}
+	return i + 2
+}
+
+/// The scanNumber function returns the end of the number or timestamp starting
+/// at `start` (timestamps contain colons, e.g. `2021-01-01T00:00Z`)
+func scanNumber(line []byte, start int) int {
+	i := start + 1
+	for i < len(line) {
+		c := line[i]
+		if !isDigit(c) && !isLetter(c) && c != '.' && c != '-' && c != '+' && c != ':' && c != '_' {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+/// The scanIdentifier function returns the end of the identifier starting at
+/// `start`, typed nulls such as `null.struct` are a single token
+func scanIdentifier(line []byte, start int) int {
+	i := start + 1
+	for i < len(line) && (isIdentifierStart(line[i]) || isDigit(line[i]) || line[i] == '.' && string(line[start:i]) == "null") {
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentifierStart(c byte) bool {
+	return isLetter(c) || c == '_' || c == '$'
+}
@@ -0,0 +1,171 @@
+package ionzst
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/// The containerReader type reads the top-level values of the outer ION
+/// container. Unlike `ion.Reader`, it reports the size of a value before its
+/// content is read, which allows oversized chunks to be rejected without
+/// allocating them
+type containerReader struct {
+	r *bufio.Reader
+	n int64 // number of bytes consumed
+}
+
+func newContainerReader(in io.Reader) *containerReader {
+	return &containerReader{r: bufio.NewReader(in)}
+}
+
+/// The text method reports whether the remaining input looks like ION text
+/// instead of a container of binary blobs
+func (c *containerReader) text() bool {
+	head, _ := c.r.Peek(textPeekSize)
+	return looksLikeText(head)
+}
+
+/// The typeNames array maps the type codes of binary ION to the type names
+var typeNames = [...]string{
+	"null", "bool", "int", "int", "float", "decimal", "timestamp", "symbol",
+	"string", "clob", "blob", "list", "sexp", "struct", "annotation", "reserved",
+}
+
+/// The next method reads the type descriptor and the length of the next value,
+/// skipping version markers and padding. It returns io.EOF at the end of the
+/// input
+func (c *containerReader) next() (code byte, length int64, err error) {
+	for {
+		td, err := c.readByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		if td == bvm[0] {
+			if err = c.readBVM(); err != nil {
+				return 0, 0, err
+			}
+			continue
+		}
+
+		code, l := td>>4, td&0x0F
+		switch {
+		case code == 0x1 || l == 0x0F:
+			length = 0 // bool and null values have no content
+		case l == 0x0E:
+			if length, err = c.readVarUint(); err != nil {
+				return 0, 0, err
+			}
+		default:
+			length = int64(l)
+		}
+		if code == 0x0 && l != 0x0F {
+			if err = c.skip(length); err != nil {
+				return 0, 0, err
+			}
+			continue // NOP padding
+		}
+		if code == 0xA && l == 0x0F {
+			return 0, 0, errors.New("unexpected null.blob value")
+		}
+		return code, length, nil
+	}
+}
+
+/// The resync method skips the input up to the next value that looks like a
+/// chunk, i.e. a blob whose content starts with a zstd frame, a gzip header or
+/// a BVM. It is used to continue after a damaged chunk and returns io.EOF if
+/// there is none
+func (c *containerReader) resync() error {
+	for {
+		head, err := c.r.Peek(1 + 9 + len(zstdMagic))
+		if len(head) == 0 {
+			return err
+		}
+		if isChunkHeader(head) {
+			return nil
+		}
+		c.r.Discard(1)
+		c.n++
+	}
+}
+
+/// The isChunkHeader function reports whether the data starts with a blob value
+/// whose content starts with a zstd frame, a gzip header or a BVM
+func isChunkHeader(head []byte) bool {
+	if head[0]>>4 != 0xA || head[0]&0x0F == 0x0F {
+		return false
+	}
+	body := head[1:]
+	if head[0]&0x0F == 0x0E {
+		_, n := readVarUint(body)
+		if n == 0 {
+			return false
+		}
+		body = body[n:]
+	}
+	return bytes.HasPrefix(body, zstdMagic[:]) || IsGzip(body) || bytes.HasPrefix(body, bvm[:])
+}
+
+/// The read method reads the content of the current value
+func (c *containerReader) read(length int64) ([]byte, error) {
+	data := make([]byte, length)
+	n, err := io.ReadFull(c.r, data)
+	c.n += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
+func (c *containerReader) skip(length int64) error {
+	n, err := io.CopyN(io.Discard, c.r, length)
+	c.n += n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (c *containerReader) readByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+/// The readBVM method reads the remainder of a version marker
+func (c *containerReader) readBVM() error {
+	rest := make([]byte, len(bvm)-1)
+	if _, err := io.ReadFull(c.r, rest); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	c.n += int64(len(rest))
+	if rest[2] != bvm[3] {
+		return errors.New("unexpected annotation value")
+	}
+	if rest[0] != bvm[1] || rest[1] != bvm[2] {
+		return fmt.Errorf("unsupported ION version %d.%d", rest[0], rest[1])
+	}
+	return nil
+}
+
+func (c *containerReader) readVarUint() (int64, error) {
+	var v int64
+	for i := 0; i < 9; i++ {
+		b, err := c.readByte()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+		v = v<<7 | int64(b&0x7F)
+		if b&0x80 != 0 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("length exceeds 63 bits")
+}
@@ -0,0 +1,147 @@
+package ionzst
+
+import (
+	"fmt"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The copyValue function copies the current value of the reader (including its
+/// annotations and, inside structs, its field name) to the writer without
+/// losing any type information
+func copyValue(r ion.Reader, w ion.Writer) error {
+	if r.IsInStruct() {
+		name, err := r.FieldName()
+		if err != nil {
+			return err
+		}
+		if err = w.FieldName(textSymbol(*name)); err != nil {
+			return err
+		}
+	}
+	annotations, err := r.Annotations()
+	if err != nil {
+		return err
+	}
+	if len(annotations) > 0 {
+		for i := range annotations {
+			annotations[i] = textSymbol(annotations[i])
+		}
+		if err = w.Annotations(annotations...); err != nil {
+			return err
+		}
+	}
+
+	t := r.Type()
+	if r.IsNull() {
+		return w.WriteNullType(t)
+	}
+
+	switch t {
+	case ion.BoolType:
+		val, err := r.BoolValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteBool(*val)
+	case ion.IntType:
+		size, err := r.IntSize()
+		if err != nil {
+			return err
+		}
+		if size == ion.BigInt {
+			val, err := r.BigIntValue()
+			if err != nil {
+				return err
+			}
+			return w.WriteBigInt(val)
+		}
+		val, err := r.Int64Value()
+		if err != nil {
+			return err
+		}
+		return w.WriteInt(*val)
+	case ion.FloatType:
+		val, err := r.FloatValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteFloat(*val)
+	case ion.DecimalType:
+		val, err := r.DecimalValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteDecimal(val)
+	case ion.TimestampType:
+		val, err := r.TimestampValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteTimestamp(*val)
+	case ion.SymbolType:
+		val, err := r.SymbolValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteSymbol(textSymbol(*val))
+	case ion.StringType:
+		val, err := r.StringValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteString(*val)
+	case ion.ClobType:
+		val, err := r.ByteValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteClob(val)
+	case ion.BlobType:
+		val, err := r.ByteValue()
+		if err != nil {
+			return err
+		}
+		return w.WriteBlob(val)
+	case ion.ListType:
+		return copyContainer(r, w, w.BeginList, w.EndList)
+	case ion.SexpType:
+		return copyContainer(r, w, w.BeginSexp, w.EndSexp)
+	case ion.StructType:
+		return copyContainer(r, w, w.BeginStruct, w.EndStruct)
+	default:
+		return fmt.Errorf("unexpected ION type %v", t)
+	}
+}
+
+/// The copyContainer function copies all values of the current container
+func copyContainer(r ion.Reader, w ion.Writer, begin, end func() error) error {
+	if err := r.StepIn(); err != nil {
+		return err
+	}
+	if err := begin(); err != nil {
+		return err
+	}
+	for r.Next() {
+		if err := copyValue(r, w); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if err := r.StepOut(); err != nil {
+		return err
+	}
+	return end()
+}
+
+/// The textSymbol function drops the symbol ID of a symbol token with known
+/// text, since the ID refers to the symbol table of the reader and has to be
+/// resolved again by the writer
+func textSymbol(st ion.SymbolToken) ion.SymbolToken {
+	if st.Text == nil {
+		return st
+	}
+	return ion.NewSymbolTokenFromString(*st.Text)
+}
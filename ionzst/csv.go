@@ -0,0 +1,159 @@
+package ionzst
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The csvEncoder type writes one CSV record per top-level struct. The columns
+/// are either given explicitly or taken from the first struct
+type csvEncoder struct {
+	w       *csv.Writer
+	header  []string
+	paths   [][]string // header split into (dotted) field paths
+	known   map[string]bool
+	strict  bool
+	started bool
+}
+
+func newCSVEncoder(out io.Writer, fields []string, strict bool) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(out), header: fields, strict: strict}
+}
+
+func (e *csvEncoder) Encode(v interface{}) error {
+	record, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("csv output requires structs, got %s", ionText(v))
+	}
+
+	// Without explicit fields the header consists of the fields of the first
+	// struct, sorted by name since the decoded structs are unordered
+
+	if !e.started {
+		e.started = true
+		if len(e.header) == 0 {
+			for name := range record {
+				e.header = append(e.header, name)
+			}
+			sort.Strings(e.header)
+		}
+		e.known = make(map[string]bool, len(e.header))
+		for _, column := range e.header {
+			path := strings.Split(column, ".")
+			e.paths = append(e.paths, path)
+			e.known[path[0]] = true
+		}
+		if err := e.w.Write(e.header); err != nil {
+			return err
+		}
+	}
+
+	if e.strict {
+		for name := range record {
+			if !e.known[name] {
+				return fmt.Errorf("field %q is not part of the csv header", name)
+			}
+		}
+	}
+
+	cells := make([]string, len(e.paths))
+	for i, path := range e.paths {
+		if val, ok := lookupPath(record, path); ok {
+			cells[i] = cellText(val)
+		}
+	}
+	return e.w.Write(cells)
+}
+
+func (e *csvEncoder) Finish() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+/// The lookupPath function returns the value at the given path of nested structs
+func lookupPath(record map[string]interface{}, path []string) (interface{}, bool) {
+	val, ok := record[path[0]]
+	if !ok || len(path) == 1 {
+		return val, ok
+	}
+	nested, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupPath(nested, path[1:])
+}
+
+/// The cellText function returns the text of a single CSV cell. Strings and
+/// symbols are written as is, all other values as ION text
+func cellText(val interface{}) string {
+	switch v := val.(type) {
+	case *string:
+		return *v
+	case string:
+		return v
+	case *ion.SymbolToken:
+		if v.Text != nil {
+			return *v.Text
+		}
+		return fmt.Sprintf("$%d", v.LocalSID)
+	default:
+		return ionText(v)
+	}
+}
+
+/// The ionText function returns the ION text representation of a value produced
+/// by the `ion.Decoder`
+func ionText(val interface{}) string {
+	text, err := ion.MarshalText(toIon(val))
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+	return string(text)
+}
+
+/// The toIon function wraps the values the ION marshaller does not handle
+/// itself (symbols and big integers)
+func toIon(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			out[key] = toIon(field)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = toIon(elem)
+		}
+		return out
+	case *ion.SymbolToken:
+		return ionSymbol{v}
+	case *big.Int:
+		return ionBigInt{v}
+	default:
+		return v
+	}
+}
+
+type ionSymbol struct {
+	*ion.SymbolToken
+}
+
+func (s ionSymbol) MarshalIon(w ion.Writer) error {
+	return w.WriteSymbol(textSymbol(*s.SymbolToken))
+}
+
+type ionBigInt struct {
+	*big.Int
+}
+
+func (i ionBigInt) MarshalIon(w ion.Writer) error {
+	return w.WriteBigInt(i.Int)
+}
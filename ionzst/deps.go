@@ -0,0 +1,199 @@
+package ionzst
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/// The dependencies type tracks the chunks preceding a selected chunk. A chunk
+/// that does not start with a BVM continues the symbol context of the previous
+/// chunks, so it cannot be decoded in isolation without their symbol tables
+type dependencies struct {
+	dec     *zstd.Decoder
+	opts    []zstd.DOption // options of the zstd decoder (e.g. dictionaries)
+	maxSize int64          // maximum size of a decompressed gzip chunk
+	pending [][]byte       // chunks since the last chunk starting with a BVM
+}
+
+/// The add method records a chunk preceding the selected chunk. Only chunks
+/// since the last chunk starting with a BVM are kept
+func (d *dependencies) add(chunk []byte) error {
+	reset, err := d.startsWithBVM(chunk)
+	if err != nil {
+		return err
+	}
+	if reset {
+		d.pending = d.pending[:0]
+	}
+	d.pending = append(d.pending, append([]byte(nil), chunk...))
+	return nil
+}
+
+/// The resolve method returns the selected chunk. Chunks that depend on the
+/// symbol tables of the preceding chunks are decompressed and prefixed with a
+/// BVM and these symbol tables
+func (d *dependencies) resolve(chunk []byte) ([]byte, error) {
+	independent, err := d.startsWithBVM(chunk)
+	if err != nil || independent {
+		return chunk, err
+	}
+
+	var tables []byte
+	for _, p := range d.pending {
+		data, err := d.decode(p)
+		if err != nil {
+			return nil, err
+		}
+		if tables, err = appendSymbolTables(tables, data); err != nil {
+			return nil, err
+		}
+	}
+	data, err := d.decode(chunk)
+	if err != nil {
+		return nil, err
+	}
+	out := append(append([]byte(nil), bvm[:]...), tables...)
+	return append(out, data...), nil
+}
+
+func (d *dependencies) close() {
+	if d.dec != nil {
+		d.dec.Close()
+	}
+}
+
+/// The startsWithBVM method reports whether the (decompressed) chunk starts with
+/// a BVM. Zstd compressed chunks are only decompressed as far as necessary
+func (d *dependencies) startsWithBVM(chunk []byte) (bool, error) {
+	if IsGzip(chunk) {
+		data, err := gunzip(chunk, d.maxSize)
+		return bytes.HasPrefix(data, bvm[:]), err
+	}
+	if !bytes.HasPrefix(chunk, zstdMagic[:]) {
+		return bytes.HasPrefix(chunk, bvm[:]), nil
+	}
+	dec, err := d.decoder()
+	if err != nil {
+		return false, err
+	}
+	if err = dec.Reset(bytes.NewReader(chunk)); err != nil {
+		return false, err
+	}
+	head := make([]byte, len(bvm))
+	n, err := io.ReadFull(dec, head)
+
+	// The stream is only read partially, it has to be released before the
+	// decoder can be used by DecodeAll again
+
+	if rerr := dec.Reset(nil); err == nil {
+		err = rerr
+	}
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return bytes.Equal(head[:n], bvm[:]), nil
+}
+
+/// The decode method decompresses a chunk, uncompressed chunks are returned as is
+func (d *dependencies) decode(chunk []byte) ([]byte, error) {
+	if IsGzip(chunk) {
+		return gunzip(chunk, d.maxSize)
+	}
+	if !bytes.HasPrefix(chunk, zstdMagic[:]) {
+		return chunk, nil
+	}
+	dec, err := d.decoder()
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeAll(chunk, nil)
+}
+
+func (d *dependencies) decoder() (*zstd.Decoder, error) {
+	if d.dec == nil {
+		dec, err := zstd.NewReader(nil, append([]zstd.DOption{zstd.WithDecoderConcurrency(1)}, d.opts...)...)
+		if err != nil {
+			return nil, err
+		}
+		d.dec = dec
+	}
+	return d.dec, nil
+}
+
+// ---
+
+/// The appendSymbolTables function appends the local symbol tables (top-level
+/// values annotated with `$ion_symbol_table`) of the given binary ION data. A
+/// BVM resets the symbol context, so all tables collected so far are dropped
+func appendSymbolTables(tables, data []byte) ([]byte, error) {
+	for len(data) > 0 {
+		if bytes.HasPrefix(data, bvm[:]) {
+			tables = tables[:0]
+			data = data[len(bvm):]
+			continue
+		}
+		n, err := valueSize(data)
+		if err != nil {
+			return nil, err
+		}
+		if isSymbolTable(data[:n]) {
+			tables = append(tables, data[:n]...)
+		}
+		data = data[n:]
+	}
+	return tables, nil
+}
+
+/// The isSymbolTable function reports whether the binary ION value is annotated
+/// with `$ion_symbol_table` (symbol ID 3) as its first annotation
+func isSymbolTable(value []byte) bool {
+	if value[0]>>4 != 0xE {
+		return false
+	}
+	body := value[1:]
+	if value[0]&0x0F == 0x0E {
+		_, n := readVarUint(body)
+		body = body[n:]
+	}
+	_, n := readVarUint(body) // length of the annotations
+	sid, _ := readVarUint(body[n:])
+	return sid == 3
+}
+
+/// The valueSize function returns the encoded size of the first binary ION
+/// value of the given data
+func valueSize(data []byte) (int, error) {
+	t, l := data[0]>>4, int(data[0]&0x0F)
+	switch {
+	case t == 0xF:
+		return 0, fmt.Errorf("invalid ION type descriptor 0x%02x", data[0])
+	case t == 0x1 || l == 0x0F:
+		return 1, nil // bool and null values have no body
+	case l == 0x0E:
+		length, n := readVarUint(data[1:])
+		if n == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		l = n + int(length)
+	}
+	if 1+l > len(data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return 1 + l, nil
+}
+
+/// The readVarUint function decodes an ION VarUInt and returns the value and the
+/// number of bytes consumed (0 if the data ends prematurely)
+func readVarUint(data []byte) (uint64, int) {
+	var v uint64
+	for i, b := range data {
+		v = v<<7 | uint64(b&0x7F)
+		if b&0x80 != 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
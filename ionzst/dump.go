@@ -0,0 +1,493 @@
+package ionzst
+
+import (
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The DumpOptions type controls the output of the Dump function
+type DumpOptions struct {
+	Format string // output format (`text`, `ion`, `ion-binary`, `json`, `jsonl` or `csv`)
+	Limit  int    // maximum number of values to dump (0 = no limit)
+	Skip   int    // number of values to discard before dumping
+	Tail   int    // only dump the last N values (0 = all, ignores Limit)
+	Pretty bool   // write indented multi-line ION text
+	Color  bool   // highlight ION text using ANSI escape sequences
+
+	// Indent is the indentation of a nesting level of pretty ION text and of
+	// the `json` format, e.g. four spaces or a tab. If empty, pretty ION text is
+	// indented by tabs and JSON by two spaces
+	Indent string
+
+	// Separator controls how top-level values of ION text are separated. By
+	// default (empty) the encoder writes a newline before every value but the
+	// first and after the last one, so a line is only completed once the next
+	// value starts. `newline` terminates every value with a newline right away
+	// and flushes the output, `none` separates values by a single space (ION
+	// text requires whitespace between scalars) and writes no newlines at all
+	Separator string
+
+	// RecordSeparator terminates every top-level value of ION text and of the
+	// `jsonl` format instead of the newline if not empty, e.g. a NUL byte for
+	// `xargs -0`. Not supported together with Separator
+	RecordSeparator string
+
+	// Symbols controls the symbol tables of the `ion-binary` format. By default
+	// (empty or `shared`) a single symbol table covers all values, which makes
+	// the output smaller. `local` writes every value with a BVM and a local
+	// symbol table of its own, so that every value can be parsed independently
+	Symbols string
+
+	// Batch writes the `json` format as a sequence of compact JSON arrays of up
+	// to Batch values, one per line, instead of a single array (0 = disabled)
+	Batch int
+
+	// Fields restricts top-level structs to the given (possibly dotted) field
+	// names. Values other than structs are dumped unchanged
+	Fields []string
+
+	// Strict rejects structs with fields that are not part of the CSV header
+	// instead of dropping these fields
+	Strict bool
+
+	// Types restricts the output to top-level values of the given ION types
+	// (e.g. `struct` or `list`), Skip is applied before and Limit after filtering
+	Types []string
+
+	// Where restricts the output to structs satisfying all predicates. Skip is
+	// applied before and Limit after filtering
+	Where []Predicate
+
+	// TimeField restricts the output to structs whose field at the given
+	// (dotted) path is a timestamp at or after Since and before Until. A zero
+	// Since or Until leaves that end of the range open
+	TimeField []string
+	Since     time.Time
+	Until     time.Time
+
+	// Flatten replaces nested structs and lists of top-level structs by fields
+	// with joined keys (e.g. `a.b` and `a.0`), using Flatten as the separator.
+	// Only supported by the JSON formats
+	Flatten string
+
+	// Sample is the probability with which a value is dumped (0 = all values),
+	// values are chosen pseudo-randomly using Seed. Every value is still decoded
+	Sample float64
+	Seed   int64
+
+	// Location converts all timestamps to the given location if not nil
+	Location *time.Location
+
+	// Hex precedes every value with a comment line holding the offset (in the
+	// decompressed data), the length and the hex dump of its binary encoding
+	Hex bool
+
+	// Offsets writes a line mapping every dumped value to its chunk and its
+	// offset in the decompressed chunk if not nil, using Chunks to locate the
+	// chunks (the input is taken as a single chunk if nil) and Object as the
+	// name of the object. Only binary ION input is supported, not together with
+	// Explode
+	Offsets *OffsetWriter
+	Chunks  *ChunkIndex
+	Object  string
+
+	// Explode dumps the elements of top-level lists as values of their own
+	// (other values are dumped unchanged), so Skip, Limit and all filters apply
+	// to the elements. Not supported together with Hex
+	Explode bool
+
+	// SortFields writes the fields of all (nested) structs of the ION formats
+	// in alphabetical order instead of the order of the decoded map, which is
+	// random. The JSON formats and CSV always sort the fields
+	SortFields bool
+
+	// Select replaces every top-level value by the nested values matched by the
+	// selector (if not nil), every match is dumped as a value of its own. The
+	// filters apply to the top-level values, Skip counts top-level values, while
+	// Limit and Tail count the matches
+	Select *Selector
+
+	// Values is incremented atomically for every decoded value if not nil (e.g.
+	// to report the progress)
+	Values *int64
+
+	// Source tags every value with its origin (e.g. the object path): ION values
+	// are annotated, structs written as JSON or CSV get a `_source` field
+	Source string
+}
+
+/// The encoder interface is implemented by all output formats
+type encoder interface {
+	Encode(v interface{}) error
+	Finish() error
+}
+
+/// The newEncoder function returns the encoder for the output format
+func newEncoder(out io.Writer, opts DumpOptions) encoder {
+	switch opts.Format {
+	case "json":
+		if opts.Batch > 0 {
+			return newJSONBatchEncoder(out, opts.Batch)
+		}
+		return newJSONEncoder(out, false, opts.Indent)
+	case "jsonl":
+		e := newJSONEncoder(out, true, "")
+		e.record = opts.RecordSeparator
+		return e
+	case "csv":
+		return newCSVEncoder(out, opts.Fields, opts.Strict)
+	case "ion":
+		return &ionEncoder{Encoder: ion.NewEncoderOpts(ion.NewBinaryWriter(out), opts.encoderOpts())}
+	default:
+		var flags ion.TextWriterOpts
+		if opts.Pretty {
+			if opts.Indent != "" && opts.Indent != "\t" {
+				out = &indentWriter{out: out, indent: []byte(opts.Indent)}
+			}
+			flags |= ion.TextWriterPretty
+		}
+		if opts.Separator == "none" || opts.RecordSeparator != "" {
+			flags |= ion.TextWriterQuietFinish
+		}
+		encOpts := opts.encoderOpts()
+		e := &ionEncoder{Encoder: ion.NewEncoderOpts(ion.NewTextWriterOpts(out, flags), encOpts), out: out, flags: flags, opts: encOpts, separator: opts.Separator, record: opts.RecordSeparator}
+		e.f, _ = out.(flusher)
+		return e
+	}
+}
+
+/// The indentWriter type replaces the tabs the pretty ION text writer indents
+/// lines with. Tabs within values are always escaped by the writer, so only the
+/// leading tabs of a line are indentation
+type indentWriter struct {
+	out    io.Writer
+	indent []byte
+	inline bool // past the indentation of the current line
+	buf    []byte
+}
+
+func (w *indentWriter) Write(p []byte) (int, error) {
+	w.buf = w.buf[:0]
+	for _, c := range p {
+		switch {
+		case c == '\n':
+			w.inline = false
+		case c == '\t' && !w.inline:
+			w.buf = append(w.buf, w.indent...)
+			continue
+		default:
+			w.inline = true
+		}
+		w.buf = append(w.buf, c)
+	}
+	if _, err := w.out.Write(w.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+/// The ionEncoder type writes ION text or binary ION. Symbols and big integers
+/// are wrapped first, since the ION marshaller does not handle the values
+/// produced by the `ion.Decoder` for these types
+type ionEncoder struct {
+	*ion.Encoder
+	out       io.Writer
+	f         flusher
+	flags     ion.TextWriterOpts
+	opts      ion.EncoderOpts
+	separator string // top-level separator of ION text (see DumpOptions)
+	record    string // terminator of every top-level value (see DumpOptions)
+	count     int
+}
+
+func (e *ionEncoder) Encode(v interface{}) error {
+	if e.separator == "none" && e.count > 0 {
+		if _, err := io.WriteString(e.out, " "); err != nil {
+			return err
+		}
+	}
+	if e.separator == "" && e.record == "" {
+		return e.Encoder.Encode(toIon(v))
+	}
+
+	// Every value gets an encoder of its own, which terminates the value with a
+	// newline (unless quiet) when it is finished
+
+	enc := ion.NewEncoderOpts(ion.NewTextWriterOpts(e.out, e.flags), e.opts)
+	if err := enc.Encode(toIon(v)); err != nil {
+		return err
+	}
+	if err := enc.Finish(); err != nil {
+		return err
+	}
+	if e.record != "" {
+		if _, err := io.WriteString(e.out, e.record); err != nil {
+			return err
+		}
+	}
+	e.count++
+	if e.separator == "newline" && e.f != nil {
+		return e.f.Flush()
+	}
+	return nil
+}
+
+/// The Dump function reads ION data from the given input and writes the values
+/// to the output stream in the format selected by the options (ION text, binary
+/// ION, JSON or CSV), optionally preceded by their hex dump
+func Dump(in io.Reader, out io.Writer, opts DumpOptions) error {
+	if opts.Format == "ion-binary" {
+		return dumpBinary(in, out, opts)
+	}
+	if opts.Color && (opts.Format == "" || opts.Format == "text") {
+		color := newColorWriter(out)
+		opts.Color = false
+		if err := Dump(in, color, opts); err != nil {
+			color.Flush()
+			return err
+		}
+		return color.Flush()
+	}
+
+	var rng *rand.Rand
+	if opts.Sample > 0 {
+		rng = rand.New(rand.NewSource(opts.Seed))
+	}
+
+	// The hex decoder keeps the offset of every value in the input. Only one
+	// decoder may be created, since the ION reader reads ahead of the values
+
+	var (
+		dec decoder
+		hex *hexDecoder
+	)
+	switch {
+	case opts.Hex || opts.Offsets != nil:
+		hex = newHexDecoder(in)
+		dec = hex
+	case opts.Explode:
+		dec = &explodeDecoder{dec: newTypedDecoder(in)}
+	default:
+		dec = newTypedDecoder(in)
+	}
+	enc := newEncoder(out, opts)
+	if opts.Hex {
+		enc = &hexEncoder{out: out, dec: hex, opts: opts}
+	}
+
+	// Skipped values are still decoded completely to keep the reader in sync
+
+	for n := 0; n < opts.Skip; n++ {
+		_, err := dec.Decode()
+		if err == ion.ErrNoInput {
+			return enc.Finish()
+		} else if err != nil {
+			return err
+		}
+		opts.decoded()
+	}
+
+	if opts.Tail > 0 {
+		return dumpTail(dec, hex, enc, opts, rng)
+	}
+
+	for n := 0; opts.Limit == 0 || n < opts.Limit; {
+		val, err := dec.Decode()
+		if err == ion.ErrNoInput {
+			break
+		} else if err != nil {
+			return err
+		}
+		opts.decoded()
+		if !opts.selects(val, dec.Type(), rng) {
+			continue
+		}
+		for _, val := range opts.selectValues(val) {
+			if opts.Limit > 0 && n >= opts.Limit {
+				break
+			}
+			n++
+			if err = enc.Encode(opts.prepare(val)); err != nil {
+				return err
+			}
+			if err = opts.offset(hex); err != nil {
+				return err
+			}
+		}
+	}
+	if err := enc.Finish(); err != nil {
+		return err
+	}
+	return nil
+}
+
+/// The selects method reports whether the value has one of the selected types,
+/// satisfies the predicates, lies within the time range and is part of the
+/// sample (if rng is not nil)
+func (opts *DumpOptions) selects(val interface{}, t ion.Type, rng *rand.Rand) bool {
+	if len(opts.Types) > 0 && !hasType(t, opts.Types) {
+		return false
+	}
+	if len(opts.Where) > 0 && !matches(val, opts.Where) {
+		return false
+	}
+	if len(opts.TimeField) > 0 && !inRange(val, opts.TimeField, opts.Since, opts.Until) {
+		return false
+	}
+	return rng == nil || rng.Float64() < opts.Sample
+}
+
+/// The selectValues method returns the values matched by the Select option,
+/// or the value itself if there is none
+func (opts *DumpOptions) selectValues(val interface{}) []interface{} {
+	if opts.Select == nil {
+		return []interface{}{val}
+	}
+	return opts.Select.Select(val)
+}
+
+/// The prepare method applies the field selection, the source tag, the
+/// flattening and the timestamp conversion (in this order) to a value before it
+/// is encoded
+func (opts *DumpOptions) prepare(val interface{}) interface{} {
+	if len(opts.Fields) > 0 {
+		val = project(val, opts.Fields)
+	}
+	if opts.Source != "" {
+		val = withSource(val, *opts)
+	}
+	if opts.Flatten != "" {
+		val = flatten(val, opts.Flatten)
+	}
+	if opts.Location != nil {
+		val = inLocation(val, opts.Location)
+	}
+	return val
+}
+
+/// The withSource function tags the value with `opts.Source`
+func withSource(val interface{}, opts DumpOptions) interface{} {
+	switch opts.Format {
+	case "json", "jsonl", "csv":
+		in, ok := val.(map[string]interface{})
+		if !ok {
+			return val
+		}
+		out := make(map[string]interface{}, len(in)+1)
+		for key, field := range in {
+			out[key] = field
+		}
+		out["_source"] = opts.Source
+		return out
+	default:
+		return annotated{annotation: opts.Source, value: val, opts: opts.encoderOpts()}
+	}
+}
+
+/// The annotated type writes a value with a single annotation
+type annotated struct {
+	annotation string
+	value      interface{}
+	opts       ion.EncoderOpts
+}
+
+func (a annotated) MarshalIon(w ion.Writer) error {
+	if err := w.Annotation(ion.NewSymbolTokenFromString(a.annotation)); err != nil {
+		return err
+	}
+	return ion.NewEncoderOpts(w, a.opts).Encode(toIon(a.value))
+}
+
+/// The encoderOpts method returns the options of the ION encoders
+func (opts *DumpOptions) encoderOpts() ion.EncoderOpts {
+	if opts.SortFields {
+		return ion.EncodeSortMaps
+	}
+	return 0
+}
+
+/// The decoded method counts a decoded value
+func (opts *DumpOptions) decoded() {
+	if opts.Values != nil {
+		atomic.AddInt64(opts.Values, 1)
+	}
+}
+
+/// The offset method writes the line of `opts.Offsets` for the last value of
+/// the hex decoder (if any)
+func (opts *DumpOptions) offset(hex *hexDecoder) error {
+	if opts.Offsets == nil {
+		return nil
+	}
+	return opts.Offsets.write(opts.Object, opts.Chunks, hex.start)
+}
+
+/// The dumpTail function dumps the last `opts.Tail` values of the decoder. The
+/// input is forward-only, so the values are kept in a ring buffer until the end
+/// of the input is reached, along with their offsets if hex is not nil
+func dumpTail(dec decoder, hex *hexDecoder, enc encoder, opts DumpOptions, rng *rand.Rand) error {
+	ring := make([]interface{}, opts.Tail)
+	starts := make([]int64, opts.Tail)
+	n := 0
+	for {
+		val, err := dec.Decode()
+		if err == ion.ErrNoInput {
+			break
+		} else if err != nil {
+			return err
+		}
+		opts.decoded()
+		if !opts.selects(val, dec.Type(), rng) {
+			continue
+		}
+		for _, val := range opts.selectValues(val) {
+			ring[n%len(ring)] = val
+			if hex != nil {
+				starts[n%len(ring)] = hex.start
+			}
+			n++
+		}
+	}
+
+	first := 0
+	if n > len(ring) {
+		first = n - len(ring)
+	}
+	for i := first; i < n; i++ {
+		if err := enc.Encode(opts.prepare(ring[i%len(ring)])); err != nil {
+			return err
+		}
+		if opts.Offsets != nil {
+			if err := opts.Offsets.write(opts.Object, opts.Chunks, starts[i%len(ring)]); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.Finish()
+}
+
+/// The flusher interface is implemented by buffered writers
+type flusher interface {
+	Flush() error
+}
+
+/// The Count function reads ION data from the given input and returns the number
+/// of top-level values. All values are decoded completely
+func Count(in io.Reader) (int64, error) {
+	dec := ion.NewTextDecoder(in)
+
+	var n int64
+	for {
+		_, err := dec.Decode()
+		if err == ion.ErrNoInput {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,34 @@
+package ionzst
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpHexUnbuffered(t *testing.T) {
+	raw := marshalBinary(t,
+		map[string]interface{}{"alpha": 1},
+		map[string]interface{}{"beta": "x"},
+	)
+
+	// A plain bytes.Reader does not buffer, so any read ahead of another decoder
+	// would be missing from the input of the hex decoder
+
+	var out bytes.Buffer
+	if err := Dump(bytes.NewReader(raw), &out, DumpOptions{Format: "text", Hex: true}); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), out.String())
+	}
+	for i, want := range []string{"{alpha:1}", "{beta:\"x\"}"} {
+		if !strings.HasPrefix(lines[2*i], "# offset=0x") {
+			t.Errorf("line %d = %q, want a hex comment", 2*i, lines[2*i])
+		}
+		if lines[2*i+1] != want {
+			t.Errorf("line %d = %q, want %q", 2*i+1, lines[2*i+1], want)
+		}
+	}
+}
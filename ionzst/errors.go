@@ -0,0 +1,21 @@
+package ionzst
+
+import "fmt"
+
+/// The ChunkError type reports an error caused by a single chunk
+type ChunkError struct {
+	Chunk    int   // index of the chunk
+	Consumed int64 // number of input bytes consumed (negative if unknown)
+	Err      error
+}
+
+func (e *ChunkError) Error() string {
+	if e.Consumed < 0 {
+		return fmt.Sprintf("chunk %d: %v", e.Chunk, e.Err)
+	}
+	return fmt.Sprintf("chunk %d (%d bytes consumed): %v", e.Chunk, e.Consumed, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
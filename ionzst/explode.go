@@ -0,0 +1,39 @@
+package ionzst
+
+import (
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The explodeDecoder type decodes the elements of top-level lists as values of
+/// their own, e.g. to write records stored in batches as one JSON document per
+/// line. All other values (including s-expressions) are decoded unchanged, empty
+/// lists are dropped
+type explodeDecoder struct {
+	dec   *typedDecoder
+	elems []interface{} // remaining elements of the current list
+	types []ion.Type    // types of the remaining elements
+	typ   ion.Type
+}
+
+func (d *explodeDecoder) Decode() (interface{}, error) {
+	for len(d.elems) == 0 {
+		val, err := d.dec.Decode()
+		if err != nil {
+			return nil, err
+		}
+		list, ok := val.([]interface{})
+		if !ok || d.dec.Type() != ion.ListType {
+			d.typ = d.dec.Type()
+			return val, nil
+		}
+		d.elems, d.types = list, d.dec.r.elems
+	}
+	val := d.elems[0]
+	d.typ = d.types[0]
+	d.elems, d.types = d.elems[1:], d.types[1:]
+	return val, nil
+}
+
+func (d *explodeDecoder) Type() ion.Type {
+	return d.typ
+}
@@ -0,0 +1,41 @@
+package ionzst
+
+import "strings"
+
+/// The project function reduces a top-level struct to the given fields. Fields
+/// are either top-level field names or dotted paths into nested structs (e.g.
+/// `user.id`). Values other than structs are returned unchanged
+func project(val interface{}, fields []string) interface{} {
+	in, ok := val.(map[string]interface{})
+	if !ok {
+		return val
+	}
+	out := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		projectPath(in, out, strings.Split(field, "."))
+	}
+	return out
+}
+
+/// The projectPath function copies the value at the given path from `in` to
+/// `out`, creating intermediate structs as needed
+func projectPath(in, out map[string]interface{}, path []string) {
+	val, ok := in[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		out[path[0]] = val
+		return
+	}
+	nested, ok := val.(map[string]interface{})
+	if !ok {
+		return
+	}
+	sub, ok := out[path[0]].(map[string]interface{})
+	if !ok {
+		sub = make(map[string]interface{})
+		out[path[0]] = sub
+	}
+	projectPath(nested, sub, path[1:])
+}
@@ -0,0 +1,43 @@
+package ionzst
+
+import "strconv"
+
+/// The flatten function replaces the nested structs and lists of a top-level
+/// struct by fields whose keys join the path to each value with the separator
+/// (e.g. `{a:{b:1},c:[x]}` becomes `{"a.b":1,"c.0":x}`). Empty structs and lists
+/// are kept as values. Values other than structs are returned unchanged
+func flatten(val interface{}, sep string) interface{} {
+	in, ok := val.(map[string]interface{})
+	if !ok {
+		return val
+	}
+	out := make(map[string]interface{}, len(in))
+	for key, field := range in {
+		flattenInto(out, key, field, sep)
+	}
+	return out
+}
+
+/// The flattenInto function adds the value (or, for non-empty containers, its
+/// elements) to `out` using the given key as prefix
+func flattenInto(out map[string]interface{}, key string, val interface{}, sep string) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			break
+		}
+		for name, field := range v {
+			flattenInto(out, key+sep+name, field, sep)
+		}
+		return
+	case []interface{}:
+		if len(v) == 0 {
+			break
+		}
+		for i, elem := range v {
+			flattenInto(out, key+sep+strconv.Itoa(i), elem, sep)
+		}
+		return
+	}
+	out[key] = val
+}
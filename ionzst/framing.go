@@ -0,0 +1,100 @@
+package ionzst
+
+import (
+	"bytes"
+	"io"
+)
+
+/// The ChunkFramer type writes decompressed chunks to the output stream and
+/// inserts a BVM before every chunk which does not start with one but brings a
+/// complete local symbol table of its own. The BVM resets the symbol context
+/// explicitly, so the symbol IDs of such a chunk never resolve against the
+/// tables of the preceding chunks. Every call to Write must pass exactly one
+/// complete chunk (as done by the Extract function and the
+/// ParallelDecompressor)
+type ChunkFramer struct {
+	out io.Writer
+}
+
+/// The NewChunkFramer function returns a ChunkFramer writing to the given output
+func NewChunkFramer(out io.Writer) *ChunkFramer {
+	return &ChunkFramer{out: out}
+}
+
+func (f *ChunkFramer) Write(chunk []byte) (int, error) {
+	if !bytes.HasPrefix(chunk, bvm[:]) && isSelfContained(chunk) {
+		if _, err := f.out.Write(bvm[:]); err != nil {
+			return 0, err
+		}
+	}
+	return f.out.Write(chunk)
+}
+
+/// The isSelfContained function reports whether the binary ION data starts with
+/// a local symbol table which replaces the current symbol context (instead of
+/// appending to it using `imports: $ion_symbol_table`)
+func isSelfContained(data []byte) bool {
+	for len(data) > 0 && data[0]>>4 == 0x0 && data[0]&0x0F != 0x0F {
+		n, err := valueSize(data)
+		if err != nil {
+			return false
+		}
+		data = data[n:] // NOP padding
+	}
+	if len(data) == 0 {
+		return false
+	}
+	n, err := valueSize(data)
+	if err != nil || !isSymbolTable(data[:n]) {
+		return false
+	}
+	return !importsCurrent(data[:n])
+}
+
+/// The importsCurrent function reports whether the symbol table (an annotated
+/// struct) has the field `imports: $ion_symbol_table`, i.e. whether it appends
+/// to the current symbol context
+func importsCurrent(table []byte) bool {
+
+	// Skip the annotation wrapper (type descriptor, length, annotations) and the
+	// type descriptor and length of the struct
+
+	body := table[1:]
+	if table[0]&0x0F == 0x0E {
+		_, n := readVarUint(body)
+		body = body[n:]
+	}
+	length, n := readVarUint(body)
+	if n == 0 || uint64(len(body)-n) < length {
+		return false
+	}
+	body = body[n+int(length):]
+	if len(body) == 0 || body[0]>>4 != 0xD {
+		return false
+	}
+	if l := body[0] & 0x0F; l == 0x0E || l == 0x01 {
+		_, n = readVarUint(body[1:])
+		body = body[1+n:]
+	} else {
+		body = body[1:]
+	}
+
+	// Fields are pairs of a symbol ID (`imports` = 6) and a value
+
+	for len(body) > 0 {
+		sid, n := readVarUint(body)
+		if n == 0 || n == len(body) {
+			return false
+		}
+		body = body[n:]
+		size, err := valueSize(body)
+		if err != nil {
+			return false
+		}
+		if sid == 6 && bytes.Equal(body[:size], []byte{0x71, 0x03}) {
+			return true
+		}
+		body = body[size:]
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package ionzst
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+// marshalBinary encodes the values as binary ION with a local symbol table
+func marshalBinary(t *testing.T, values ...interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := ion.NewBinaryWriter(&buf)
+	enc := ion.NewEncoder(w)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// decodeAll decodes all values of the ION data (binary or text)
+func decodeAll(t *testing.T, data []byte) []interface{} {
+	t.Helper()
+	dec := ion.NewTextDecoder(bytes.NewReader(data))
+	var values []interface{}
+	for {
+		val, err := dec.Decode()
+		if err == ion.ErrNoInput {
+			return values
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, val)
+	}
+}
+
+func TestChunkFramerSymbols(t *testing.T) {
+
+	// Both chunks bring a local symbol table without BVM, mapping SID 10 to a
+	// different field name, so the framer inserts a BVM before each of them
+
+	chunks := [][]byte{
+		marshalBinary(t, map[string]interface{}{"alpha": 1})[len(bvm):],
+		marshalBinary(t, map[string]interface{}{"beta": 2})[len(bvm):],
+	}
+
+	var stream bytes.Buffer
+	f := NewChunkFramer(&stream)
+	for _, chunk := range chunks {
+		if _, err := f.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := append(append(append(bvm[:], chunks[0]...), bvm[:]...), chunks[1]...)
+	if !bytes.Equal(stream.Bytes(), want) {
+		t.Errorf("framed stream = %x, want %x", stream.Bytes(), want)
+	}
+
+	got := decodeAll(t, stream.Bytes())
+	values := []interface{}{
+		map[string]interface{}{"alpha": 1},
+		map[string]interface{}{"beta": 2},
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("got %v, want %v", got, values)
+	}
+}
@@ -0,0 +1,34 @@
+package ionzst
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+var gzipMagic = [...]byte{0x1F, 0x8B}
+
+/// The IsGzip function reports whether the data starts with a gzip header
+func IsGzip(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic[:])
+}
+
+/// The gunzip function decompresses a gzip compressed chunk. Decompressed chunks
+/// larger than maxSize bytes are rejected (0 = no limit), like
+/// `zstd.WithDecoderMaxMemory` does for zstd compressed chunks
+func gunzip(chunk []byte, maxSize int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(chunk))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if maxSize <= 0 {
+		return io.ReadAll(zr)
+	}
+	data, err := io.ReadAll(io.LimitReader(zr, maxSize+1))
+	if err == nil && int64(len(data)) > maxSize {
+		err = fmt.Errorf("decompressed size exceeds the limit of %d bytes", maxSize)
+	}
+	return data, err
+}
@@ -0,0 +1,144 @@
+package ionzst
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The decoder interface is implemented by typedDecoder and hexDecoder. Type
+/// returns the ION type of the last decoded value
+type decoder interface {
+	Decode() (interface{}, error)
+	Type() ion.Type
+}
+
+/// The hexDecoder type decodes the top-level values of binary ION one by one,
+/// keeping the encoded bytes of the last value and their offset in the input.
+/// Each value is decoded along with the symbol tables in effect for it
+type hexDecoder struct {
+	r      *bufio.Reader
+	off    int64    // offset of the next value
+	start  int64    // offset of the last value
+	value  []byte   // encoded bytes of the last value
+	typ    ion.Type // type of the last value
+	tables []byte   // local symbol tables since the last BVM
+}
+
+func (d *hexDecoder) Type() ion.Type {
+	return d.typ
+}
+
+func newHexDecoder(in io.Reader) *hexDecoder {
+	return &hexDecoder{r: bufio.NewReader(in)}
+}
+
+func (d *hexDecoder) Decode() (interface{}, error) {
+	for {
+		start := d.off
+		value, err := d.next()
+		if err == io.EOF {
+			return nil, ion.ErrNoInput
+		} else if err != nil {
+			return nil, fmt.Errorf("offset 0x%x: %w", start, err)
+		}
+		switch {
+		case bytes.Equal(value, bvm[:]):
+			d.tables = d.tables[:0]
+		case value[0]>>4 == 0x0 && value[0]&0x0F != 0x0F:
+			// NOP padding
+		case isSymbolTable(value):
+			d.tables = append(d.tables, value...)
+		default:
+			d.start, d.value = start, value
+			data := append(append(append([]byte(nil), bvm[:]...), d.tables...), value...)
+			dec := newTypedDecoder(bytes.NewReader(data))
+			val, err := dec.Decode()
+			d.typ = dec.Type()
+			return val, err
+		}
+	}
+}
+
+/// The next method reads the encoded bytes of the next top-level value (or BVM)
+func (d *hexDecoder) next() ([]byte, error) {
+	td, err := d.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if td[0] == bvm[0] {
+		return d.read(len(bvm))
+	}
+
+	// The length of the value follows the type descriptor as a VarUInt if it
+	// does not fit into the type descriptor itself
+
+	head, _ := d.r.Peek(1 + 9)
+	n, err := valueSizeHeader(head)
+	if err != nil {
+		return nil, err
+	}
+	return d.read(n)
+}
+
+func (d *hexDecoder) read(n int) ([]byte, error) {
+	value := make([]byte, n)
+	if _, err := io.ReadFull(d.r, value); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	d.off += int64(n)
+	return value, nil
+}
+
+/// The valueSizeHeader function returns the encoded size of a binary ION value
+/// from its type descriptor and length alone
+func valueSizeHeader(head []byte) (int, error) {
+	t, l := head[0]>>4, int(head[0]&0x0F)
+	switch {
+	case t == 0xF:
+		return 0, fmt.Errorf("invalid ION type descriptor 0x%02x", head[0])
+	case t == 0x1 || l == 0x0F:
+		return 1, nil
+	case l == 0x0E:
+		length, n := readVarUint(head[1:])
+		if n == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 1 + n + int(length), nil
+	}
+	return 1 + l, nil
+}
+
+/// The hexEncoder type writes every value preceded by a comment line with the
+/// offset, the length and the hex dump of its encoded bytes
+type hexEncoder struct {
+	out  io.Writer
+	dec  *hexDecoder
+	opts DumpOptions
+}
+
+func (e *hexEncoder) Encode(v interface{}) error {
+	_, err := fmt.Fprintf(e.out, "# offset=0x%x len=%d %x\n", e.dec.start, len(e.dec.value), e.dec.value)
+	if err != nil {
+		return err
+	}
+
+	// Every value gets an encoder of its own, so that it is written completely
+	// before the next comment line
+
+	enc := newEncoder(e.out, e.opts)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Finish()
+}
+
+func (e *hexEncoder) Finish() error {
+	return nil
+}
@@ -0,0 +1,108 @@
+package ionzst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/klauspost/compress/zstd"
+)
+
+/// The IndexPaths function returns the object paths referenced by a Sneller
+/// index (the `index` object of a table, e.g. `db/<db>/<table>/index`), i.e. the
+/// `path` fields of its descriptors in the order they appear, without
+/// duplicates. The index is compressed ION whose descriptors may be stored in
+/// compressed blobs of their own, these are searched as well. Trailing data that
+/// is not ION (e.g. the signature of the index) is ignored once paths have been
+/// found. The options are passed to the zstd decoder
+func IndexPaths(index []byte, opts ...zstd.DOption) ([]string, error) {
+	dec, err := zstd.NewReader(nil, append([]zstd.DOption{zstd.WithDecoderConcurrency(1)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	w := &indexWalker{dec: dec, seen: make(map[string]bool)}
+	err = w.parse(index)
+	if len(w.paths) == 0 {
+		if err == nil {
+			err = errors.New("no objects referenced")
+		}
+		return nil, fmt.Errorf("invalid index: %w", err)
+	}
+	return w.paths, nil
+}
+
+/// The indexWalker type collects the paths of the descriptors of an index
+type indexWalker struct {
+	dec   *zstd.Decoder
+	paths []string
+	seen  map[string]bool
+}
+
+/// The parse method searches the (possibly zstd compressed) ION data
+func (w *indexWalker) parse(data []byte) error {
+	if bytes.HasPrefix(data, zstdMagic[:]) {
+
+		// The data following the compressed frame (e.g. a signature) makes the
+		// decoder fail after the frame has been decoded completely
+
+		if err := w.dec.Reset(bytes.NewReader(data)); err != nil {
+			return err
+		}
+		decompressed, err := io.ReadAll(w.dec)
+		if len(decompressed) == 0 {
+			return err
+		}
+		data = decompressed
+	}
+	return w.walk(ion.NewReader(NewBVMReader(bytes.NewReader(data))))
+}
+
+/// The walk method visits all values of the current container, collecting the
+/// strings of `path` fields and searching blobs holding ION data
+func (w *indexWalker) walk(r ion.Reader) error {
+	for r.Next() {
+		if r.IsNull() {
+			continue
+		}
+		switch r.Type() {
+		case ion.StructType, ion.ListType, ion.SexpType:
+			if err := r.StepIn(); err != nil {
+				return err
+			}
+			if err := w.walk(r); err != nil {
+				return err
+			}
+			if err := r.StepOut(); err != nil {
+				return err
+			}
+		case ion.StringType:
+			name, err := r.FieldName()
+			if err != nil || name == nil || name.Text == nil || *name.Text != "path" {
+				continue
+			}
+			path, err := r.StringValue()
+			if err != nil {
+				return err
+			}
+			if path != nil && !w.seen[*path] {
+				w.seen[*path] = true
+				w.paths = append(w.paths, *path)
+			}
+		case ion.BlobType:
+			data, err := r.ByteValue()
+			if err != nil {
+				return err
+			}
+
+			// Blobs which do not hold ION data are skipped
+			if bytes.HasPrefix(data, zstdMagic[:]) || bytes.HasPrefix(data, bvm[:]) {
+				w.parse(data)
+			}
+		}
+	}
+	return r.Err()
+}
@@ -0,0 +1,424 @@
+// Package ionzst implements the processing of Sneller `.ion.zst` objects:
+// extracting the ION chunks from the outer container, decompressing them and
+// dumping the contained values
+package ionzst
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/// The ObjectSource interface provides access to a (possibly remote) object:
+/// `Open` streams the object from its beginning, `Stat` returns its size and
+/// `ReadAt` allows random access reads (e.g. of the trailer)
+type ObjectSource interface {
+	Open(ctx context.Context) (io.ReadCloser, error)
+	Stat() (int64, error)
+	io.ReaderAt
+}
+
+/// The trailerOffsetSize constant is the size of the trailer offset stored at
+/// the end of every object, i.e. the minimum size of a valid object
+const trailerOffsetSize = 4
+
+/// The SizeWithoutTrailer function returns the size of the requested object
+/// excluding the size of the Sneller specific trailer and offset
+func SizeWithoutTrailer(src ObjectSource) (int64, error) {
+
+	// The Sneller 'ion.zst' format contains a trailer and a 4-byte offset pointing
+	// to the beginning of this trailer
+
+	size, err := src.Stat()
+	if err != nil {
+		return -1, err
+	}
+	if size < trailerOffsetSize {
+		return -1, fmt.Errorf("object too small to be a valid Sneller file (%d bytes)", size)
+	}
+
+	data := make([]byte, trailerOffsetSize)
+
+	_, err = src.ReadAt(data, size-trailerOffsetSize)
+	if err != nil && err != io.EOF {
+		return -1, err
+	}
+
+	offset := binary.LittleEndian.Uint32(data)
+	if int64(offset) > size-trailerOffsetSize {
+
+		// ION text has no trailer, the whole object is dumped (the last bytes of
+		// text always make up an offset beyond the end of small objects)
+
+		if isText(src) {
+			return size, nil
+		}
+		return -1, fmt.Errorf("invalid trailer offset %d (object size %d)", offset, size)
+	}
+
+	return size - int64(offset) - trailerOffsetSize, nil
+}
+
+/// The isText function reports whether the object starts with ION text
+func isText(src ObjectSource) bool {
+	head := make([]byte, textPeekSize)
+	n, err := src.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return false
+	}
+	return looksLikeText(head[:n])
+}
+
+/// The Extractor type extracts the ION data chunks from the outer ION container
+type Extractor struct {
+	First   int   // index of the first chunk to extract
+	Count   int   // number of chunks to extract (0 = all chunks from First on)
+	MaxSize int64 // maximum size of a chunk in bytes, also decompressed (0 = no limit)
+
+	// MaxChunks aborts the extraction with an error once more than MaxChunks
+	// chunks would be passed on (0 = no limit), e.g. to protect automated jobs
+	// from unexpectedly large objects
+	MaxChunks int
+
+	// DecoderOptions are passed to the zstd decoder used to resolve the symbol
+	// tables a selected chunk depends on (e.g. `zstd.WithDecoderDicts`)
+	DecoderOptions []zstd.DOption
+
+	// Skip is called with the error of every chunk that cannot be extracted
+	// (or whose processing fails with a ChunkError) if not nil. The chunk is
+	// dropped and the extraction continues with the next value of the input
+	// that looks like a chunk, instead of failing
+	Skip func(err error)
+}
+
+/// The Extract function extracts all ION data chunks from the outer ION
+/// container and writes them to the output stream. Errors include the index of
+/// the failing chunk and the number of input bytes consumed so far
+func Extract(in io.Reader, out io.Writer) error {
+	return Extractor{}.Extract(in, out)
+}
+
+/// The ExtractChunk function extracts only the chunk with the given (0-based)
+/// index and writes it to the output stream. The remaining input is not read. If
+/// the chunk depends on the symbol tables of the preceding chunks, it is written
+/// decompressed and prefixed with these symbol tables
+func ExtractChunk(in io.Reader, out io.Writer, index int) error {
+	if index < 0 {
+		return fmt.Errorf("invalid chunk index %d", index)
+	}
+	return Extractor{First: index, Count: 1}.Extract(in, out)
+}
+
+/// The Extract method writes the selected chunks to the output stream, every
+/// chunk is passed to a single Write call. Chunks exceeding the maximum size are
+/// rejected before they are read. The input following the last selected chunk
+/// is not read. If the first selected chunk depends on the symbol tables of the
+/// preceding chunks, it is written decompressed and prefixed with these tables
+func (e Extractor) Extract(in io.Reader, out io.Writer) error {
+	return e.ExtractChunks(in, func(index int, chunk []byte) error {
+		_, err := out.Write(chunk)
+		return err
+	})
+}
+
+/// The ExtractChunks method passes the selected chunks one by one to the given
+/// function along with their (0-based) index in the object, e.g. to write every
+/// chunk to a file of its own. An error returned by the function stops the
+/// extraction and is returned as is
+func (e Extractor) ExtractChunks(in io.Reader, fn func(index int, chunk []byte) error) error {
+
+	// The Sneller 'ion.zst' format stores multiple chunks of ION data in `blob`
+	// values of the outer ION container
+
+	deps := dependencies{opts: e.DecoderOptions, maxSize: e.MaxSize}
+	defer deps.close()
+
+	last := -1
+	if e.Count > 0 {
+		last = e.First + e.Count - 1
+	}
+
+	r := newContainerReader(in)
+
+	// ION text (e.g. an object written by a text encoder by mistake) is not a
+	// container of blobs, the whole input is passed on as a single chunk
+
+	if r.text() {
+		return e.textChunk(r, fn)
+	}
+
+	chunk, emitted := 0, 0
+	for ; ; chunk++ {
+		val, err := e.readChunk(r, chunk)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+
+			// The container is damaged at this point, the extraction continues
+			// with the next value that looks like a chunk
+
+			if e.Skip == nil {
+				return err
+			}
+			e.Skip(err)
+			if err = r.resync(); err == io.EOF {
+				chunk++
+				break
+			} else if err != nil {
+				return err
+			}
+			continue
+		}
+		if e.First > 0 {
+			if chunk < e.First {
+				err = deps.add(val)
+			} else if chunk == e.First {
+				val, err = deps.resolve(val)
+			}
+			if err != nil {
+				err = &ChunkError{Chunk: chunk, Consumed: -1, Err: err}
+				if e.Skip == nil {
+					return err
+				}
+				e.Skip(err)
+				continue
+			}
+			if chunk < e.First {
+				continue
+			}
+		}
+		if e.MaxChunks > 0 && emitted >= e.MaxChunks {
+			return &ChunkError{Chunk: chunk, Consumed: -1, Err: fmt.Errorf("limit of %d chunks exceeded", e.MaxChunks)}
+		}
+		emitted++
+		err = fn(chunk, val)
+		var cerr *ChunkError
+		if err != nil && e.Skip != nil && errors.As(err, &cerr) {
+			e.Skip(err)
+			err = nil
+		}
+		if err != nil || chunk == last {
+			return err
+		}
+	}
+	switch {
+	case (e.First > 0 || last >= 0) && chunk <= e.First:
+		return fmt.Errorf("chunk %d requested, but only %d chunks present", e.First, chunk)
+	case last >= 0:
+		return fmt.Errorf("chunks %d to %d requested, but only %d chunks present", e.First, last, chunk)
+	}
+	return nil
+}
+
+/// The readChunk method reads the next chunk (the content of a blob value) of
+/// the outer container. It returns io.EOF at the end of the input
+func (e Extractor) readChunk(r *containerReader, chunk int) ([]byte, error) {
+	code, length, err := r.next()
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, &ChunkError{Chunk: chunk, Consumed: r.n, Err: err}
+	}
+	if code != 0xA {
+		return nil, &ChunkError{Chunk: chunk, Consumed: r.n, Err: fmt.Errorf("unexpected %s value, expected blob", typeNames[code])}
+	}
+	if e.MaxSize > 0 && length > e.MaxSize {
+		return nil, &ChunkError{Chunk: chunk, Consumed: r.n, Err: fmt.Errorf("size of %d bytes exceeds the limit of %d bytes", length, e.MaxSize)}
+	}
+	val, err := r.read(length)
+	if err != nil {
+		return nil, &ChunkError{Chunk: chunk, Consumed: r.n, Err: err}
+	}
+	return val, nil
+}
+
+/// The textChunk method passes the remaining input (ION text) to the given
+/// function as chunk 0
+func (e Extractor) textChunk(r *containerReader, fn func(index int, chunk []byte) error) error {
+	if e.First > 0 {
+		return fmt.Errorf("chunk %d requested, but the input is ION text (a single chunk)", e.First)
+	}
+	val, err := io.ReadAll(r.r)
+	if err != nil {
+		return &ChunkError{Chunk: 0, Consumed: r.n, Err: err}
+	}
+	r.n += int64(len(val))
+	if e.MaxSize > 0 && int64(len(val)) > e.MaxSize {
+		return &ChunkError{Chunk: 0, Consumed: 0, Err: fmt.Errorf("size of %d bytes exceeds the limit of %d bytes", len(val), e.MaxSize)}
+	}
+	return fn(0, val)
+}
+
+/// The Decompress function decompresses the given input data and writes the
+/// resulting bytes to the output stream. The options are passed to the zstd
+/// decoder
+func Decompress(in io.Reader, out io.Writer, opts ...zstd.DOption) error {
+	dec, err := zstd.NewReader(in, opts...)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	// The decoder writes the decompressed blocks directly to the output, without
+	// copying them into an intermediate buffer first
+
+	_, err = dec.WriteTo(out)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ---
+
+var bvm = [...]byte{0xE0, 0x01, 0x00, 0xEA}
+
+type bvmReader struct {
+	r    io.Reader
+	head []byte // bytes to emit before reading from r
+	init bool
+	err  error // sticky error of the initial peek
+}
+
+func (r *bvmReader) Read(p []byte) (n int, err error) {
+
+	// The Sneller 'ion.zst' format does not prepend the ION BVM. We have to add it to
+	// allow the `ion.TextDecoder` to detect binary input format
+
+	if err := r.start(); err != nil {
+		return 0, err
+	}
+	if len(r.head) > 0 {
+
+		// The rest of the buffer is filled right away, so that the first read
+		// is not cut short to the few bytes of the head
+
+		n := copy(p, r.head)
+		r.head = r.head[n:]
+		if len(r.head) == 0 && n < len(p) {
+			m, err := r.r.Read(p[n:])
+			return n + m, err
+		}
+		return n, nil
+	}
+	return r.r.Read(p)
+}
+
+/// The WriteTo method writes the head and passes the rest of the input on using
+/// io.Copy, so that the fast paths of the wrapped reader (io.WriterTo, e.g. of a
+/// bufio.Reader) or of the writer (io.ReaderFrom) are used
+func (r *bvmReader) WriteTo(w io.Writer) (int64, error) {
+	if err := r.start(); err != nil {
+		return 0, err
+	}
+	var n int64
+	if len(r.head) > 0 {
+		m, err := w.Write(r.head)
+		n += int64(m)
+		r.head = r.head[m:]
+		if err != nil {
+			return n, err
+		}
+	}
+	m, err := io.Copy(w, r.r)
+	return n + m, err
+}
+
+/// The start method peeks at the beginning of the input on the first call and
+/// returns the (sticky) error of the peek
+func (r *bvmReader) start() error {
+	if !r.init {
+		r.init = true
+		r.err = r.peek()
+	}
+	return r.err
+}
+
+/// The peek method inspects the beginning of the wrapped stream. A BVM is only
+/// prepended if the stream does not start with one already and does not look
+/// like ION text, which is decoded as text without a BVM
+func (r *bvmReader) peek() error {
+	head := make([]byte, textPeekSize)
+	n, err := io.ReadFull(r.r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+
+	if looksLikeText(head) {
+		r.head = head
+		return nil
+	}
+
+	if n >= len(bvm) && head[0] == bvm[0] && head[3] == bvm[3] {
+		if head[1] != bvm[1] || head[2] != bvm[2] {
+			return fmt.Errorf("unsupported ION version %d.%d", head[1], head[2])
+		}
+		r.head = head
+		return checkTypeDescriptor(head[len(bvm):])
+	}
+	r.head = append(append([]byte{}, bvm[:]...), head...)
+	return checkTypeDescriptor(head)
+}
+
+/// The checkTypeDescriptor function verifies that the data following the BVM
+/// starts with a valid ION type descriptor (type code 0xF is reserved)
+func checkTypeDescriptor(data []byte) error {
+	if len(data) > 0 && data[0]>>4 == 0xF {
+		return fmt.Errorf("invalid ION type descriptor 0x%02x after version marker", data[0])
+	}
+	return nil
+}
+
+/// The textPeekSize constant is the number of leading bytes inspected by
+/// looksLikeText
+const textPeekSize = 32
+
+/// The looksLikeText function reports whether the beginning of a stream looks
+/// like ION text instead of binary ION: after optional whitespace it starts with
+/// a struct, list or s-expression or with a `$ion` symbol (e.g. the version
+/// marker `$ion_1_0`), and it holds no control characters. Binary ION starting
+/// with one of these bytes (e.g. 0x7B, a symbol of 11 bytes) is very unlikely to
+/// be printable as well
+func looksLikeText(data []byte) bool {
+	if len(data) > textPeekSize {
+		data = data[:textPeekSize]
+	}
+	text := bytes.TrimLeft(data, " \t\r\n")
+	if len(text) == 0 {
+		return false
+	}
+	if text[0] != '{' && text[0] != '[' && text[0] != '(' && !bytes.HasPrefix(text, []byte("$ion")) {
+		return false
+	}
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\r' && c != '\n' || c == 0x7F {
+			return false
+		}
+	}
+	return true
+}
+
+/// The NewBVMReader function returns a reader prepending the ION binary version
+/// marker to the given input, unless the input already starts with one or is
+/// ION text
+func NewBVMReader(input io.Reader) io.Reader {
+	return &bvmReader{r: input}
+}
+
+/// The countingReader type counts the bytes read from the underlying reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,100 @@
+package ionzst
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSizeWithoutTrailerSmall(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{},
+		{0x01},
+		{0x01, 0x02, 0x03},
+	} {
+		size, err := SizeWithoutTrailer(memSource{bytes.NewReader(data)})
+		if err == nil {
+			t.Errorf("object of %d bytes: got size %d, want an error", len(data), size)
+		}
+	}
+}
+
+func TestSizeWithoutTrailerInvalidOffset(t *testing.T) {
+
+	// The trailer offset points before the start of the object
+
+	data := []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0x00, 0x00, 0x00}
+	if size, err := SizeWithoutTrailer(memSource{bytes.NewReader(data)}); err == nil {
+		t.Errorf("got size %d, want an error", size)
+	}
+}
+
+func TestLooksLikeText(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"{a:1}", true},
+		{"  \n[1,2]", true},
+		{"(+ 1 2)", true},
+		{"$ion_1_0 {a:1}", true},
+		{"\xE0\x01\x00\xEA", false},
+		{"{\x00\x01", false},
+		{"a:1", false},
+		{"   ", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := looksLikeText([]byte(test.data)); got != test.want {
+			t.Errorf("looksLikeText(%q) = %v, want %v", test.data, got, test.want)
+		}
+	}
+}
+
+// copyWriter copies the data written into a buffer, like a write to a file or
+// socket would, but does not implement io.ReaderFrom
+type copyWriter struct {
+	buf [64 << 10]byte
+}
+
+func (w *copyWriter) Write(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		n += copy(w.buf[:], p[n:])
+	}
+	return len(p), nil
+}
+
+func BenchmarkBVMReader(b *testing.B) {
+
+	// 64 MiB of structs without BVM, so that the reader prepends one
+
+	data := bytes.Repeat([]byte{0xD3, 0x8A, 0x21, 0x01}, 16<<20)
+
+	for _, bench := range []struct {
+		name string
+		copy func(dst io.Writer, src io.Reader) (int64, error)
+	}{
+		// io.Copy uses the WriteTo method of the BVM reader, which hands the
+		// input on to the WriteTo method of the bufio.Reader
+		{"WriteTo", io.Copy},
+
+		// The WriteTo method is hidden, io.Copy has to go through Read
+		{"Read", func(dst io.Writer, src io.Reader) (int64, error) {
+			return io.Copy(dst, struct{ io.Reader }{src})
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			w := new(copyWriter)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r := NewBVMReader(bufio.NewReader(bytes.NewReader(data)))
+				if _, err := bench.copy(w, r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package ionzst
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The jsonEncoder type writes all top-level values as a single (indented) JSON
+/// array. In `lines` mode every value is written as compact JSON document on a
+/// single line and flushed right away. In batch mode the values are written as
+/// compact JSON arrays of up to `batch` values, one array per line
+type jsonEncoder struct {
+	out    io.Writer
+	buf    bytes.Buffer
+	enc    *json.Encoder
+	f      flusher
+	lines  bool
+	batch  int
+	indent string // indentation of a nesting level (not used in `lines` mode)
+	record string // terminator of every value in `lines` mode (default newline)
+	count  int
+}
+
+/// The newJSONEncoder function returns an encoder of a JSON array indented by
+/// the given string per level (two spaces if empty) or of JSON lines
+func newJSONEncoder(out io.Writer, lines bool, indent string) *jsonEncoder {
+	if indent == "" {
+		indent = "  "
+	}
+	e := &jsonEncoder{out: out, lines: lines, indent: indent}
+	e.enc = json.NewEncoder(&e.buf)
+	if !lines {
+		e.enc.SetIndent(indent, indent)
+	}
+	e.f, _ = out.(flusher)
+	return e
+}
+
+/// The newJSONBatchEncoder function returns an encoder writing JSON arrays of
+/// up to the given number of values, every array on a line of its own
+func newJSONBatchEncoder(out io.Writer, batch int) *jsonEncoder {
+	e := &jsonEncoder{out: out, batch: batch}
+	e.enc = json.NewEncoder(&e.buf)
+	e.f, _ = out.(flusher)
+	return e
+}
+
+func (e *jsonEncoder) Encode(v interface{}) error {
+	e.buf.Reset()
+	switch {
+	case e.batch > 0:
+		if e.count%e.batch == 0 {
+			e.buf.WriteByte('[')
+		} else {
+			e.buf.WriteByte(',')
+		}
+	case !e.lines:
+		if e.count == 0 {
+			e.buf.WriteString("[\n")
+		} else {
+			e.buf.WriteString(",\n")
+		}
+		e.buf.WriteString(e.indent)
+	}
+	if err := e.enc.Encode(toJSON(v)); err != nil {
+		return err
+	}
+	e.count++
+
+	// The encoder terminates every value with a newline, which is only kept
+	// in `lines` mode (the array separators follow the values otherwise)
+
+	data := e.buf.Bytes()
+	if !e.lines || e.record != "" {
+		data = bytes.TrimSuffix(data, []byte("\n"))
+	}
+	if e.record != "" {
+		data = append(data, e.record...)
+	}
+	complete := e.batch > 0 && e.count%e.batch == 0
+	if complete {
+		data = append(data, "]\n"...)
+	}
+	if _, err := e.out.Write(data); err != nil {
+		return err
+	}
+	if (e.lines || complete) && e.f != nil {
+		return e.f.Flush()
+	}
+	return nil
+}
+
+/// The Finish method closes the JSON array, an empty input results in `[]`. In
+/// batch mode only the last incomplete batch is closed
+func (e *jsonEncoder) Finish() error {
+	if e.lines {
+		return nil
+	}
+	if e.batch > 0 {
+		if e.count%e.batch == 0 {
+			return nil
+		}
+		_, err := io.WriteString(e.out, "]\n")
+		return err
+	}
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.out, end)
+	return err
+}
+
+/// The toJSON function converts a value produced by the `ion.Decoder` into a
+/// value with a deterministic JSON representation
+func toJSON(val interface{}) interface{} {
+
+	// Timestamps are rendered as ISO-8601 strings, symbols as their (quoted) text,
+	// decimals as exact JSON numbers and blobs/clobs as base64 strings (which is
+	// the default `encoding/json` behavior for byte slices)
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			out[key] = toJSON(field)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = toJSON(elem)
+		}
+		return out
+	case *ion.Timestamp:
+		return v.GetDateTime().Format(time.RFC3339Nano)
+	case *ion.SymbolToken:
+		if v.Text != nil {
+			return *v.Text
+		}
+		return fmt.Sprintf("$%d", v.LocalSID)
+	case *ion.Decimal:
+		n, exp := v.CoEx()
+		return json.Number(fmt.Sprintf("%se%d", n.String(), exp))
+	case *big.Int:
+		return json.Number(v.String())
+	case *float64:
+		return floatToJSON(*v)
+	case *string:
+		return *v
+	default:
+		return v
+	}
+}
+
+/// The floatToJSON function converts a float into a JSON value. NaN and
+/// infinity have no JSON representation and are emitted as strings
+func floatToJSON(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return f
+}
@@ -0,0 +1,106 @@
+package ionzst
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+/// The ChunkIndex type records where every decompressed chunk passed to the
+/// underlying writer starts in the decompressed stream, so that the offset of a
+/// value in the stream can be mapped back to its chunk. Chunks are numbered in
+/// the order they are written, starting at `first`, chunks dropped in between
+/// must be reported by calling Skip. Every call to Write must pass exactly one
+/// complete chunk. If `framed` is set, the underlying writer is a ChunkFramer,
+/// the BVMs it inserts are not part of any chunk
+type ChunkIndex struct {
+	out    io.Writer
+	first  int
+	framed bool
+
+	mu     sync.Mutex
+	next   int     // index of the next chunk
+	pos    int64   // size of the stream written so far
+	starts []int64 // offset of every chunk in the stream
+	chunks []int   // index of every chunk
+}
+
+/// The NewChunkIndex function returns a ChunkIndex writing to the given output
+func NewChunkIndex(out io.Writer, first int, framed bool) *ChunkIndex {
+	return &ChunkIndex{out: out, first: first, next: first, framed: framed}
+}
+
+/// The Skip method skips the index of a chunk dropped instead of written (e.g.
+/// by the Skip function of the ParallelDecompressor)
+func (x *ChunkIndex) Skip() {
+	x.mu.Lock()
+	x.next++
+	x.mu.Unlock()
+}
+
+func (x *ChunkIndex) Write(chunk []byte) (int, error) {
+
+	// The chunk is recorded before it is passed on, since the values it holds
+	// may be decoded (concurrently) as soon as it has been written
+
+	x.mu.Lock()
+	if x.framed && !bytes.HasPrefix(chunk, bvm[:]) && isSelfContained(chunk) {
+		x.pos += int64(len(bvm))
+	}
+	x.starts = append(x.starts, x.pos)
+	x.chunks = append(x.chunks, x.next)
+	x.next++
+	x.pos += int64(len(chunk))
+	x.mu.Unlock()
+	return x.out.Write(chunk)
+}
+
+/// The Locate method returns the index of the chunk holding the given offset
+/// of the decompressed stream and the offset relative to the start of the
+/// (decompressed) chunk
+func (x *ChunkIndex) Locate(off int64) (int, int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i := sort.Search(len(x.starts), func(i int) bool { return x.starts[i] > off }) - 1
+	if i < 0 {
+		return x.first, off
+	}
+	return x.chunks[i], off - x.starts[i]
+}
+
+/// The OffsetWriter type writes a tab separated line for every dumped value,
+/// mapping the number of the record in the output (counting from 0 across all
+/// objects) to its object, its chunk and its offset in the decompressed chunk.
+/// The first line is a header naming the columns
+type OffsetWriter struct {
+	out     io.Writer
+	records int64
+	header  bool
+}
+
+/// The NewOffsetWriter function returns an OffsetWriter writing to the given
+/// output
+func NewOffsetWriter(out io.Writer) *OffsetWriter {
+	return &OffsetWriter{out: out}
+}
+
+/// The write method writes the line of the next record, which is found at the
+/// given offset of the decompressed stream. Without chunk index, the stream is
+/// taken as a single chunk
+func (w *OffsetWriter) write(object string, chunks *ChunkIndex, off int64) error {
+	if !w.header {
+		w.header = true
+		if _, err := fmt.Fprintln(w.out, "record\tobject\tchunk\toffset"); err != nil {
+			return err
+		}
+	}
+	chunk := 0
+	if chunks != nil {
+		chunk, off = chunks.Locate(off)
+	}
+	_, err := fmt.Fprintf(w.out, "%d\t%s\t%d\t%d\n", w.records, object, chunk, off)
+	w.records++
+	return err
+}
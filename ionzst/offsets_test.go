@@ -0,0 +1,38 @@
+package ionzst
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestDumpOffsets(t *testing.T) {
+
+	// Every chunk holds a symbol table and a single struct {alpha:N}, encoded as
+	// the 4 bytes D3 8A 21 0N at the end of the chunk
+
+	chunks := [][]byte{
+		marshalBinary(t, map[string]interface{}{"alpha": 1}),
+		marshalBinary(t, map[string]interface{}{"alpha": 2}),
+	}
+	var stream bytes.Buffer
+	index := NewChunkIndex(&stream, 5, false)
+	for _, chunk := range chunks {
+		if _, err := index.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out, offsets bytes.Buffer
+	opts := DumpOptions{Format: "jsonl", Offsets: NewOffsetWriter(&offsets), Chunks: index, Object: "obj"}
+	if err := Dump(bytes.NewReader(stream.Bytes()), &out, opts); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if got, want := out.String(), "{\"alpha\":1}\n{\"alpha\":2}\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	want := fmt.Sprintf("record\tobject\tchunk\toffset\n0\tobj\t5\t%d\n1\tobj\t6\t%d\n", len(chunks[0])-4, len(chunks[1])-4)
+	if got := offsets.String(); got != want {
+		t.Errorf("offsets = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+package ionzst
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/// The ParallelDecompressor type decompresses chunks using a pool of zstd
+/// decoders. Every call to Write must pass exactly one complete chunk (as done by
+/// the Extract function); the decompressed chunks are written to the output
+/// stream in their original order. Gzip compressed chunks are decompressed as
+/// well, chunks that are not compressed are passed through verbatim
+///
+/// The order is a correctness requirement (values are dumped in the order they
+/// are stored, and chunks without a BVM depend on the symbol tables of their
+/// predecessors). It holds by construction: every chunk gets its own result
+/// channel, which Write queues in submission order and the collector drains in
+/// that same order
+type ParallelDecompressor struct {
+	out     io.Writer
+	jobs    chan chunkJob
+	pending chan chan chunkResult
+	chunks  int
+	workers sync.WaitGroup
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+
+	// MaxSize is the maximum size of a decompressed gzip chunk in bytes (0 = no
+	// limit), zstd chunks are limited by `zstd.WithDecoderMaxMemory`. It must be
+	// set before the first Write
+	MaxSize int64
+
+	// Skip is called with the error of every chunk that cannot be decompressed
+	// if not nil, the chunk is dropped instead of failing. It must be set before
+	// the first Write and is called from another goroutine
+	Skip func(err error)
+}
+
+type chunkJob struct {
+	index  int
+	data   []byte
+	result chan chunkResult
+}
+
+type chunkResult struct {
+	data []byte
+	err  error
+}
+
+/// The NewParallelDecompressor function returns a ParallelDecompressor using the
+/// given number of workers. The options are passed to the zstd decoders (e.g.
+/// `zstd.WithDecoderMaxMemory` to limit the decompressed size of a chunk)
+func NewParallelDecompressor(out io.Writer, workers int, opts ...zstd.DOption) (*ParallelDecompressor, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &ParallelDecompressor{
+		out:     out,
+		jobs:    make(chan chunkJob),
+		pending: make(chan chan chunkResult, workers),
+		done:    make(chan struct{}),
+	}
+	decs := make([]*zstd.Decoder, workers)
+	for i := range decs {
+		dec, err := zstd.NewReader(nil, append([]zstd.DOption{zstd.WithDecoderConcurrency(1)}, opts...)...)
+		if err != nil {
+			for _, d := range decs[:i] {
+				d.Close()
+			}
+			return nil, err
+		}
+		decs[i] = dec
+	}
+	p.workers.Add(workers)
+	for _, dec := range decs {
+		go p.work(dec)
+	}
+	go p.collect()
+	return p, nil
+}
+
+/// The work method decompresses chunks until the job queue is closed
+func (p *ParallelDecompressor) work(dec *zstd.Decoder) {
+	defer p.workers.Done()
+	defer dec.Close()
+	for job := range p.jobs {
+		data, err := decompressChunk(dec, job.index, job.data, p.MaxSize)
+		job.result <- chunkResult{data: data, err: err}
+	}
+}
+
+/// The collect method writes the decompressed chunks in submission order
+func (p *ParallelDecompressor) collect() {
+	defer close(p.done)
+
+	// Pending results are drained even after an error so that neither the
+	// workers nor Write block forever
+
+	for result := range p.pending {
+		res := <-result
+		if p.failed() != nil {
+			continue
+		}
+		err := res.err
+		if err != nil && p.Skip != nil {
+			p.Skip(err)
+			continue
+		}
+		if err == nil {
+			_, err = p.out.Write(res.data)
+		}
+		if err != nil {
+			p.mu.Lock()
+			p.err = err
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *ParallelDecompressor) failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+/// The Write method queues a single compressed chunk for decompression
+func (p *ParallelDecompressor) Write(chunk []byte) (int, error) {
+	if err := p.failed(); err != nil {
+		return 0, err
+	}
+	job := chunkJob{
+		index:  p.chunks,
+		data:   append([]byte(nil), chunk...),
+		result: make(chan chunkResult, 1),
+	}
+	p.chunks++
+	p.pending <- job.result
+	p.jobs <- job
+	return len(chunk), nil
+}
+
+var zstdMagic = [...]byte{0x28, 0xB5, 0x2F, 0xFD}
+
+/// The decompressChunk function decompresses a single (zstd or gzip compressed)
+/// chunk, maxSize limits the size of decompressed gzip chunks. Chunks which are not compressed but already contain ION data (binary
+/// with or without BVM, or text) are passed through verbatim
+func decompressChunk(dec *zstd.Decoder, index int, chunk []byte, maxSize int64) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(chunk, zstdMagic[:]):
+		data, err := dec.DecodeAll(chunk, nil)
+		if err != nil {
+			return nil, &ChunkError{Chunk: index, Consumed: -1, Err: err}
+		}
+		return data, nil
+	case IsGzip(chunk):
+		data, err := gunzip(chunk, maxSize)
+		if err != nil {
+			return nil, &ChunkError{Chunk: index, Consumed: -1, Err: err}
+		}
+		return data, nil
+	case bytes.HasPrefix(chunk, bvm[:]), looksLikeText(chunk), isBinaryChunk(chunk):
+		return chunk, nil
+	default:
+		return nil, &ChunkError{Chunk: index, Consumed: -1, Err: errors.New("neither zstd compressed nor ION data")}
+	}
+}
+
+/// The isBinaryChunk function reports whether the chunk holds binary ION without
+/// a BVM, i.e. it starts with a valid type descriptor (e.g. the annotation
+/// wrapper of a `$ion_symbol_table`) and the first value fits into the chunk
+func isBinaryChunk(chunk []byte) bool {
+	if len(chunk) == 0 {
+		return false
+	}
+	if chunk[0]>>4 == 0xE && chunk[0]&0x0F < 3 {
+		return false // BVM or annotation wrapper too short to hold a value
+	}
+	_, err := valueSize(chunk)
+	return err == nil
+}
+
+/// The Close method waits for all queued chunks to be written and returns the
+/// first error that occurred
+func (p *ParallelDecompressor) Close() error {
+	close(p.jobs)
+	close(p.pending)
+	p.workers.Wait()
+	<-p.done
+	return p.failed()
+}
@@ -0,0 +1,165 @@
+package ionzst
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// sequenceChunk returns a chunk tagged with its sequence number, padded to the
+// given size
+func sequenceChunk(seq, size int) []byte {
+	tag := []byte(fmt.Sprintf("<chunk %d>", seq))
+	return append(tag, bytes.Repeat([]byte{byte('a' + seq%26)}, size)...)
+}
+
+func TestParallelDecompressorOrder(t *testing.T) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	// Every chunk except each fourth one is tiny, so the workers finish the later
+	// chunks long before the large ones preceding them
+
+	var chunks, want [][]byte
+	for seq := 0; seq < 32; seq++ {
+		size := 16
+		if seq%4 == 0 {
+			size = 8 << 20
+		}
+		data := sequenceChunk(seq, size)
+		want = append(want, data)
+		chunks = append(chunks, enc.EncodeAll(data, nil))
+	}
+
+	var out bytes.Buffer
+	p, err := NewParallelDecompressor(&out, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		if _, err := p.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := out.Bytes()
+	for seq, data := range want {
+		if !bytes.HasPrefix(got, data) {
+			n := len(got)
+			if n > 16 {
+				n = 16
+			}
+			t.Fatalf("chunk %d: got %q..., want %q...", seq, got[:n], data[:16])
+		}
+		got = got[len(data):]
+	}
+	if len(got) > 0 {
+		t.Errorf("%d trailing bytes", len(got))
+	}
+}
+
+func TestDecompressTextChunk(t *testing.T) {
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	// A text chunk is passed through verbatim, whether stored as is or
+	// compressed, and the framer never inserts a BVM before it
+
+	text := []byte("{a:1,b:\"x\"}\n{a:2}\n")
+	var stream bytes.Buffer
+	f := NewChunkFramer(&stream)
+	for i, chunk := range [][]byte{text, enc.EncodeAll(text, nil)} {
+		data, err := decompressChunk(dec, i, chunk, 0)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if !bytes.Equal(data, text) {
+			t.Errorf("chunk %d = %q, want %q", i, data, text)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := append(append([]byte(nil), text...), text...); !bytes.Equal(stream.Bytes(), want) {
+		t.Errorf("framed stream = %q, want %q", stream.Bytes(), want)
+	}
+
+	var out bytes.Buffer
+	if err := Dump(bytes.NewReader(stream.Bytes()), &out, DumpOptions{Format: "jsonl"}); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	want := "{\"a\":1,\"b\":\"x\"}\n{\"a\":2}\n"
+	if got := out.String(); got != want+want {
+		t.Errorf("output = %q, want %q", got, want+want)
+	}
+}
+
+func TestDecompressBinaryChunk(t *testing.T) {
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	// An uncompressed chunk without BVM, starting with its local symbol table,
+	// is passed through verbatim
+
+	chunk := marshalBinary(t, map[string]interface{}{"alpha": 1})[len(bvm):]
+	data, err := decompressChunk(dec, 0, chunk, 0)
+	if err != nil {
+		t.Fatalf("decompressChunk: %v", err)
+	}
+	if !bytes.Equal(data, chunk) {
+		t.Errorf("chunk = %x, want %x", data, chunk)
+	}
+
+	for _, chunk := range [][]byte{
+		{0xF0, 0x00, 0x00},       // reserved type code
+		{0x8A, 0x61},             // string longer than the chunk
+		{0xE1, 0x00, 0x00, 0x00}, // invalid annotation wrapper
+	} {
+		if _, err := decompressChunk(dec, 1, chunk, 0); err == nil {
+			t.Errorf("chunk %x: got no error", chunk)
+		}
+	}
+}
+
+func TestDecompressGzipLimit(t *testing.T) {
+	data := bytes.Repeat([]byte{0x21, 0x01}, 512)
+	var chunk bytes.Buffer
+	zw := gzip.NewWriter(&chunk)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, maxSize := range []int64{0, int64(len(data))} {
+		if got, err := decompressChunk(nil, 0, chunk.Bytes(), maxSize); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("limit %d: got %d bytes, %v, want %d bytes", maxSize, len(got), err, len(data))
+		}
+	}
+
+	_, err := decompressChunk(nil, 3, chunk.Bytes(), int64(len(data))-1)
+	var cerr *ChunkError
+	if !errors.As(err, &cerr) || cerr.Chunk != 3 {
+		t.Errorf("got %v, want a ChunkError of chunk 3", err)
+	}
+}
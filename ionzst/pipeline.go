@@ -0,0 +1,45 @@
+package ionzst
+
+import (
+	"io"
+	"sync"
+)
+
+/// The Pipeline function extracts, decompresses and dumps (as ION text) the
+/// chunks of an `.ion.zst` object. The input has to end before the trailer,
+/// e.g. by limiting the object stream to the size returned by
+/// SizeWithoutTrailer. The stages run concurrently, connected by pipes; the
+/// first error of any stage is returned
+func Pipeline(src io.Reader, dst io.Writer) error {
+	extractedReader, extractedWriter := io.Pipe()
+	decompReader, decompWriter := io.Pipe()
+
+	// Every stage closes its output with its own error, which ends the next
+	// stage, and closes its input when it stops, which ends the previous stage
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs[0] = Extract(NewBVMReader(src), extractedWriter)
+		extractedWriter.CloseWithError(errs[0])
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = Decompress(extractedReader, decompWriter)
+		extractedReader.CloseWithError(errs[1])
+		decompWriter.CloseWithError(errs[1])
+	}()
+	errs[2] = Dump(decompReader, dst, DumpOptions{})
+	decompReader.CloseWithError(errs[2])
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && err != io.ErrClosedPipe {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package ionzst
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// memSource serves an in-memory object as ObjectSource
+type memSource struct {
+	*bytes.Reader
+}
+
+func (s memSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(s.Reader, 0, s.Size())), nil
+}
+
+func (s memSource) Stat() (int64, error) {
+	return s.Size(), nil
+}
+
+// buildObject compresses every chunk, stores it as blob and appends the trailer
+func buildObject(t *testing.T, chunks ...[]byte) []byte {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	var obj bytes.Buffer
+	var offsets []int64
+	for _, chunk := range chunks {
+		offsets = append(offsets, int64(obj.Len()))
+		if _, err := writeBlob(&obj, enc.EncodeAll(chunk, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeTrailer(&obj, int64(obj.Len()), 1<<20, offsets); err != nil {
+		t.Fatal(err)
+	}
+	return obj.Bytes()
+}
+
+func TestPipelineRoundTrip(t *testing.T) {
+	chunks := [][]byte{
+		marshalBinary(t,
+			map[string]interface{}{"id": 1, "name": "a"},
+			map[string]interface{}{"id": 2, "tags": []interface{}{"x", "y"}},
+		),
+		marshalBinary(t,
+			map[string]interface{}{"id": 3, "nested": map[string]interface{}{"ok": true}},
+			"plain string",
+		),
+	}
+	var want []interface{}
+	for _, chunk := range chunks {
+		want = append(want, decodeAll(t, chunk)...)
+	}
+	if len(want) != 4 {
+		t.Fatalf("got %d values to compare, want 4", len(want))
+	}
+
+	obj := buildObject(t, chunks...)
+	size, err := SizeWithoutTrailer(memSource{bytes.NewReader(obj)})
+	if err != nil {
+		t.Fatalf("SizeWithoutTrailer: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Pipeline(bytes.NewReader(obj[:size]), &out); err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if got := decodeAll(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package ionzst
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/klauspost/compress/zstd"
+)
+
+/// The Repack function reads decompressed ION data from the given input and
+/// writes a complete `.ion.zst` object to the output stream: the values are
+/// split into chunks of approximately `chunkSize` decompressed bytes, every
+/// chunk is compressed and stored as a `blob` of the outer container, followed
+/// by a fresh trailer and the 4-byte trailer offset
+func Repack(in io.Reader, out io.Writer, chunkSize int) error {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	// The chunk size is measured on the input side, since the binary writer
+	// only produces output when a chunk is finished
+
+	cr := &countingReader{r: in}
+	r := ion.NewReader(cr)
+
+	var (
+		chunk   bytes.Buffer
+		w       ion.Writer
+		start   int64   // input position of the current chunk
+		written int64   // size of the outer container written so far
+		offsets []int64 // start offsets of the written chunks
+	)
+	flush := func() error {
+		if w == nil {
+			return nil
+		}
+		if err := w.Finish(); err != nil {
+			return err
+		}
+		offsets = append(offsets, written)
+		n, err := writeBlob(out, enc.EncodeAll(chunk.Bytes(), nil))
+		written += n
+		chunk.Reset()
+		w = nil
+		return err
+	}
+
+	for r.Next() {
+		if w == nil {
+			w = ion.NewBinaryWriter(&chunk)
+			start = cr.n
+		}
+		if err := copyValue(r, w); err != nil {
+			return err
+		}
+		if cr.n-start >= int64(chunkSize) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return writeTrailer(out, written, chunkSize, offsets)
+}
+
+/// The writeBlob function writes the given data as binary ION `blob` value
+/// (without a BVM) and returns the number of bytes written
+func writeBlob(out io.Writer, data []byte) (int64, error) {
+
+	// The type descriptor stores lengths up to 13 bytes inline, longer values
+	// are followed by the length encoded as VarUInt
+
+	var head []byte
+	if len(data) < 14 {
+		head = []byte{0xA0 | byte(len(data))}
+	} else {
+		head = append([]byte{0xAE}, varUint(uint64(len(data)))...)
+	}
+	n, err := out.Write(head)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := out.Write(data)
+	return int64(n + m), err
+}
+
+/// The varUint function encodes the given value as ION VarUInt
+func varUint(v uint64) []byte {
+	out := []byte{byte(v&0x7F) | 0x80}
+	for v >>= 7; v > 0; v >>= 7 {
+		out = append([]byte{byte(v & 0x7F)}, out...)
+	}
+	return out
+}
+
+/// The writeTrailer function writes the Sneller trailer describing the written
+/// chunks, followed by the 4-byte trailer offset
+func writeTrailer(out io.Writer, offset int64, chunkSize int, offsets []int64) error {
+	var buf bytes.Buffer
+	w := ion.NewBinaryWriter(&buf)
+
+	w.BeginStruct()
+	w.FieldName(ion.NewSymbolTokenFromString("version"))
+	w.WriteInt(1)
+	w.FieldName(ion.NewSymbolTokenFromString("offset"))
+	w.WriteInt(offset)
+	w.FieldName(ion.NewSymbolTokenFromString("algo"))
+	w.WriteString("zstd")
+	w.FieldName(ion.NewSymbolTokenFromString("blockshift"))
+	w.WriteInt(int64(bits.Len(uint(chunkSize - 1))))
+	w.FieldName(ion.NewSymbolTokenFromString("blocks"))
+	w.BeginList()
+	for _, off := range offsets {
+		w.BeginStruct()
+		w.FieldName(ion.NewSymbolTokenFromString("offset"))
+		w.WriteInt(off)
+		w.FieldName(ion.NewSymbolTokenFromString("chunks"))
+		w.WriteInt(1)
+		w.EndStruct()
+	}
+	w.EndList()
+	w.EndStruct()
+	if err := w.Finish(); err != nil {
+		return err
+	}
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(buf.Len()))
+	buf.Write(size[:])
+	_, err := out.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,122 @@
+package ionzst
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The jsonTypes array lists the JSON schema types in the order they are
+/// written
+var jsonTypes = [...]string{"null", "boolean", "integer", "number", "string", "array", "object"}
+
+/// The jsonType function maps an ION type to its JSON schema type. Timestamps,
+/// symbols and lobs are represented as strings in JSON
+func jsonType(t ion.Type) string {
+	switch t {
+	case ion.NullType:
+		return "null"
+	case ion.BoolType:
+		return "boolean"
+	case ion.IntType:
+		return "integer"
+	case ion.FloatType, ion.DecimalType:
+		return "number"
+	case ion.ListType, ion.SexpType:
+		return "array"
+	case ion.StructType:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+/// The Schema function reads ION data from the given input and writes a JSON
+/// schema describing the union of all values to the output stream: the types of
+/// every (nested) struct field and list element, and the fields present in all
+/// structs as `required`. Only the first `limit` values are scanned (0 = all)
+func Schema(in io.Reader, out io.Writer, limit int64) error {
+	r := ion.NewReader(in)
+	root := newProfile()
+	for (limit == 0 || root.count < limit) && r.Next() {
+		if err := root.observe(r, -1); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	schema := root.schema()
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	return err
+}
+
+/// The schema method returns the JSON schema of the observed values
+func (p *profile) schema() map[string]interface{} {
+	schema := make(map[string]interface{})
+
+	seen := make(map[string]bool)
+	for t := range p.types {
+		seen[jsonType(t)] = true
+	}
+	if p.nulls > 0 {
+		seen["null"] = true // typed nulls (e.g. `null.int`) are null in JSON
+	}
+	if seen["number"] {
+		delete(seen, "integer") // integers are numbers as well
+	}
+	var types []string
+	for _, t := range jsonTypes {
+		if seen[t] {
+			types = append(types, t)
+		}
+	}
+	switch len(types) {
+	case 0:
+	case 1:
+		schema["type"] = types[0]
+	default:
+		schema["type"] = types
+	}
+
+	// Strings which are all timestamps or all blobs are annotated with their
+	// format or encoding
+
+	if seen["string"] {
+		texts := p.types[ion.StringType] + p.types[ion.SymbolType] + p.types[ion.ClobType]
+		switch {
+		case texts == 0 && p.types[ion.BlobType] == 0:
+			schema["format"] = "date-time"
+		case texts == 0 && p.types[ion.TimestampType] == 0:
+			schema["contentEncoding"] = "base64"
+		}
+	}
+
+	if p.items != nil {
+		schema["items"] = p.items.schema()
+	}
+	if len(p.fields) > 0 {
+		properties := make(map[string]interface{}, len(p.fields))
+		var required []string
+		for name, f := range p.fields {
+			properties[name] = f.schema()
+			if f.count >= p.structs {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+	return schema
+}
@@ -0,0 +1,142 @@
+package ionzst
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/// The Selector type is a parsed path expression selecting nested values of the
+/// top-level values, e.g. `$.events[*].name`. Supported are field access
+/// (`.name` or `['name']`), list indexes (`[0]`) and wildcards matching all
+/// elements of a list (`[*]`) or all fields of a struct (`.*`)
+type Selector struct {
+	expr  string
+	steps []selectorStep
+}
+
+/// The selectorStep type is a single step of a Selector: a field name, a list
+/// index or a wildcard
+type selectorStep struct {
+	field    string
+	index    int
+	wildcard bool
+}
+
+/// The ParseSelector function parses a path expression. Expressions start with
+/// `$` (the top-level value), followed by any number of steps
+func ParseSelector(expr string) (*Selector, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid selector %q: must start with '$'", expr)
+	}
+	s := &Selector{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		var (
+			step selectorStep
+			err  error
+		)
+		switch rest[0] {
+		case '.':
+			step, rest, err = parseFieldStep(rest[1:])
+		case '[':
+			step, rest, err = parseBracketStep(rest[1:])
+		default:
+			err = fmt.Errorf("unexpected %q, expected '.' or '['", rest[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", expr, err)
+		}
+		s.steps = append(s.steps, step)
+	}
+	return s, nil
+}
+
+/// The parseFieldStep function parses the step following a dot: a field name
+/// (up to the next `.` or `[`) or the wildcard `*`
+func parseFieldStep(rest string) (selectorStep, string, error) {
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		end = len(rest)
+	}
+	name := rest[:end]
+	switch name {
+	case "":
+		return selectorStep{}, "", fmt.Errorf("empty field name")
+	case "*":
+		return selectorStep{wildcard: true}, rest[end:], nil
+	}
+	return selectorStep{field: name, index: -1}, rest[end:], nil
+}
+
+/// The parseBracketStep function parses the step following a `[` up to the
+/// closing bracket: the wildcard `*`, a list index or a quoted field name
+func parseBracketStep(rest string) (selectorStep, string, error) {
+	if rest != "" && (rest[0] == '\'' || rest[0] == '"') {
+		end := strings.IndexByte(rest[1:], rest[0])
+		if end < 0 || !strings.HasPrefix(rest[end+2:], "]") {
+			return selectorStep{}, "", fmt.Errorf("unterminated field name %s", rest)
+		}
+		return selectorStep{field: rest[1 : end+1], index: -1}, rest[end+3:], nil
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return selectorStep{}, "", fmt.Errorf("missing ']'")
+	}
+	if rest[:end] == "*" {
+		return selectorStep{wildcard: true}, rest[end+1:], nil
+	}
+	index, err := strconv.Atoi(rest[:end])
+	if err != nil || index < 0 {
+		return selectorStep{}, "", fmt.Errorf("invalid list index %q", rest[:end])
+	}
+	return selectorStep{index: index}, rest[end+1:], nil
+}
+
+func (s *Selector) String() string {
+	return s.expr
+}
+
+/// The Select method returns the values matched by the selector in the given
+/// (decoded) value, in document order. The fields of structs matched by a
+/// wildcard are visited in alphabetical order, since decoded structs do not
+/// keep the order of their fields
+func (s *Selector) Select(val interface{}) []interface{} {
+	return selectSteps(val, s.steps, nil)
+}
+
+func selectSteps(val interface{}, steps []selectorStep, out []interface{}) []interface{} {
+	if len(steps) == 0 {
+		return append(out, val)
+	}
+	step, rest := steps[0], steps[1:]
+	switch v := val.(type) {
+	case map[string]interface{}:
+		switch {
+		case step.wildcard:
+			names := make([]string, 0, len(v))
+			for name := range v {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				out = selectSteps(v[name], rest, out)
+			}
+		case step.index < 0:
+			if field, ok := v[step.field]; ok {
+				out = selectSteps(field, rest, out)
+			}
+		}
+	case []interface{}:
+		switch {
+		case step.wildcard:
+			for _, elem := range v {
+				out = selectSteps(elem, rest, out)
+			}
+		case step.index >= 0 && step.index < len(v):
+			out = selectSteps(v[step.index], rest, out)
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package ionzst
+
+import (
+	"bytes"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/// The ChunkSplitter type turns single chunks into standalone binary ION, e.g.
+/// to write every chunk to a file of its own. Chunks are passed in their
+/// original order (as done by the ExtractChunks method); a chunk which continues
+/// the symbol context of the preceding chunks is prefixed with their symbol
+/// tables, so that it can be decoded without them
+type ChunkSplitter struct {
+	MaxSize int64 // maximum size of a decompressed gzip chunk in bytes (0 = no limit)
+
+	dec        *zstd.Decoder
+	compressed bool
+	tables     []byte // local symbol tables since the last BVM
+}
+
+/// The NewChunkSplitter function returns a ChunkSplitter for compressed
+/// (`.ion.zst`) or uncompressed (`.ion`) chunks
+func NewChunkSplitter(compressed bool, opts ...zstd.DOption) (*ChunkSplitter, error) {
+	s := &ChunkSplitter{compressed: compressed}
+	if compressed {
+		dec, err := zstd.NewReader(nil, append([]zstd.DOption{zstd.WithDecoderConcurrency(1)}, opts...)...)
+		if err != nil {
+			return nil, err
+		}
+		s.dec = dec
+	}
+	return s, nil
+}
+
+/// The Split method decompresses the chunk with the given index and returns it
+/// as standalone binary ION starting with a BVM
+func (s *ChunkSplitter) Split(index int, chunk []byte) ([]byte, error) {
+	data := chunk
+	if s.compressed {
+		var err error
+		data, err = decompressChunk(s.dec, index, chunk, s.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A chunk with a symbol table of its own replaces the symbol context just
+	// like a chunk starting with a BVM
+
+	out := make([]byte, 0, len(bvm)+len(s.tables)+len(data))
+	if !bytes.HasPrefix(data, bvm[:]) {
+		out = append(out, bvm[:]...)
+		if isSelfContained(data) {
+			s.tables = s.tables[:0]
+		} else {
+			out = append(out, s.tables...)
+		}
+	}
+	out = append(out, data...)
+
+	tables, err := appendSymbolTables(s.tables, data)
+	if err != nil {
+		return nil, &ChunkError{Chunk: index, Consumed: -1, Err: err}
+	}
+	s.tables = tables
+	return out, nil
+}
+
+/// The Close method releases the zstd decoder
+func (s *ChunkSplitter) Close() error {
+	if s.dec != nil {
+		s.dec.Close()
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package ionzst
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The profile type tallies the values observed at one position of the data:
+/// the top-level values, a struct field or the elements of lists
+type profile struct {
+	count   int64
+	nulls   int64              // null values (including typed nulls like `null.int`)
+	types   map[ion.Type]int64 // occurrences per ION type (`null.int` counts as int)
+	structs int64              // non-null structs
+	fields  map[string]*profile
+	items   *profile // elements of lists and s-expressions
+}
+
+func newProfile() *profile {
+	return &profile{types: make(map[ion.Type]int64)}
+}
+
+/// The observe method tallies the current value of the reader. Structs, lists
+/// and s-expressions are stepped into up to the given depth (negative = no
+/// limit), their fields and elements are tallied in profiles of their own
+func (p *profile) observe(r ion.Reader, depth int) error {
+	t := r.Type()
+	p.count++
+	p.types[t]++
+	if r.IsNull() {
+		p.nulls++
+		return nil
+	}
+	if depth == 0 || (t != ion.StructType && t != ion.ListType && t != ion.SexpType) {
+		return nil
+	}
+
+	if t == ion.StructType {
+		p.structs++
+	}
+	if err := r.StepIn(); err != nil {
+		return err
+	}
+	for r.Next() {
+		var child *profile
+		if t == ion.StructType {
+			name, err := r.FieldName()
+			if err != nil {
+				return err
+			}
+			key := fmt.Sprintf("$%d", name.LocalSID)
+			if name.Text != nil {
+				key = *name.Text
+			}
+			child = p.field(key)
+		} else {
+			if p.items == nil {
+				p.items = newProfile()
+			}
+			child = p.items
+		}
+		if err := child.observe(r, depth-1); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return r.StepOut()
+}
+
+/// The field method returns the profile of the struct field with the given name
+func (p *profile) field(name string) *profile {
+	if p.fields == nil {
+		p.fields = make(map[string]*profile)
+	}
+	f := p.fields[name]
+	if f == nil {
+		f = newProfile()
+		p.fields[name] = f
+	}
+	return f
+}
+
+/// The Stats function reads ION data from the given input and writes a summary
+/// of the fields of all top-level structs to the output stream: how often every
+/// field appears, how often it is null and the distribution of its ION types.
+/// Fields are sorted by their frequency, values other than structs are only
+/// counted
+func Stats(in io.Reader, out io.Writer) error {
+	r := ion.NewReader(in)
+	root := newProfile()
+	for r.Next() {
+		if err := root.observe(r, 1); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(root.fields))
+	width := len("field")
+	for name := range root.fields {
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if a, b := root.fields[names[i]].count, root.fields[names[j]].count; a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+
+	if _, err := fmt.Fprintf(out, "%d values, %d structs, %d fields\n\n", root.count, root.structs, len(names)); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(out, "%-*s %12s %12s  %s\n", width, "field", "count", "nulls", "types"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		f := root.fields[name]
+		if _, err := fmt.Fprintf(out, "%-*s %12d %12d  %s\n", width, name, f.count, f.nulls, f.distribution()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/// The distribution method formats the type counts of the profile, most
+/// frequent type first, e.g. `int 990 (99.0%), string 10 (1.0%)`
+func (p *profile) distribution() string {
+	types := make([]ion.Type, 0, len(p.types))
+	for t := range p.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if p.types[types[i]] != p.types[types[j]] {
+			return p.types[types[i]] > p.types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	parts := make([]string, len(types))
+	for i, t := range types {
+		n := p.types[t]
+		parts[i] = fmt.Sprintf("%s %d (%.1f%%)", t, n, 100*float64(n)/float64(p.count))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,127 @@
+package ionzst
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The symbolEntry type is an entry of a local symbol table
+type symbolEntry struct {
+	id   uint64
+	text string
+}
+
+/// The SymbolWriter type writes the entries of the local symbol tables (symbol
+/// ID and text) of the decompressed chunks passed to Write to the output
+/// stream. Every call to Write must pass exactly one complete chunk, in their
+/// original order (as done by the ParallelDecompressor); chunks are numbered
+/// starting at `first`, chunks dropped in between must be reported by calling
+/// Skip. Entries are deduplicated across chunks, every entry is printed once
+/// together with the chunk that introduced it
+type SymbolWriter struct {
+	out    io.Writer
+	next   int    // index of the next chunk
+	tables []byte // local symbol tables since the last BVM
+	seen   map[symbolEntry]bool
+}
+
+/// The NewSymbolWriter function returns a SymbolWriter writing to the given
+/// output
+func NewSymbolWriter(out io.Writer, first int) *SymbolWriter {
+	return &SymbolWriter{out: out, next: first, seen: make(map[symbolEntry]bool)}
+}
+
+/// The Skip method skips the index of a chunk dropped instead of written (e.g.
+/// by the Skip function of the ParallelDecompressor)
+func (w *SymbolWriter) Skip() {
+	w.next++
+}
+
+func (w *SymbolWriter) Write(chunk []byte) (int, error) {
+	index := w.next
+	w.next++
+
+	// A binary chunk without a BVM is read with the symbol tables of the
+	// preceding chunks, unless it brings a table of its own (see Split)
+
+	input := chunk
+	binary := !looksLikeText(chunk)
+	if binary && !bytes.HasPrefix(chunk, bvm[:]) {
+		if isSelfContained(chunk) {
+			w.tables = w.tables[:0]
+		} else if len(w.tables) > 0 {
+			input = make([]byte, 0, len(bvm)+len(w.tables)+len(chunk))
+			input = append(append(append(input, bvm[:]...), w.tables...), chunk...)
+		}
+	}
+
+	entries, err := w.scan(NewBVMReader(bytes.NewReader(input)))
+	if err != nil {
+		return 0, &ChunkError{Chunk: index, Consumed: -1, Err: err}
+	}
+	if binary {
+		tables, err := appendSymbolTables(w.tables, chunk)
+		if err != nil {
+			return 0, &ChunkError{Chunk: index, Consumed: -1, Err: err}
+		}
+		w.tables = tables
+	}
+	if err := writeSymbols(w.out, entries, index); err != nil {
+		return 0, err
+	}
+	return len(chunk), nil
+}
+
+/// The scan method reads all values of the ION data and returns the entries of
+/// its local symbol tables that have not been seen before
+func (w *SymbolWriter) scan(in io.Reader) ([]symbolEntry, error) {
+	var (
+		entries []symbolEntry
+		table   ion.SymbolTable
+	)
+	r := ion.NewReader(in)
+	for r.Next() {
+		st := r.SymbolTable()
+		if st == table {
+			continue
+		}
+		table = st
+
+		symbols := st.Symbols()
+		first := st.MaxID() - uint64(len(symbols)) + 1
+		for i, text := range symbols {
+			e := symbolEntry{id: first + uint64(i), text: text}
+			if !w.seen[e] {
+				w.seen[e] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, r.Err()
+}
+
+/// The writeSymbols function writes the entries introduced by the given chunk
+func writeSymbols(out io.Writer, entries []symbolEntry, chunk int) error {
+	for _, e := range entries {
+		if _, err := fmt.Fprintf(out, "$%-6d %q (chunk %d)\n", e.id, e.text, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/// The Symbols function reads ION data from the given input and writes the
+/// entries of all local symbol tables to the output stream, like SymbolWriter.
+/// The input is taken as a single chunk (e.g. a gzip stream of ION data, which
+/// has no chunks)
+func Symbols(in io.Reader, out io.Writer) error {
+	w := NewSymbolWriter(out, 0)
+	entries, err := w.scan(in)
+	if err != nil {
+		return err
+	}
+	return writeSymbols(out, entries, 0)
+}
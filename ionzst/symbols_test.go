@@ -0,0 +1,31 @@
+package ionzst
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSymbolWriterChunks(t *testing.T) {
+
+	// The second chunk has no symbol table of its own, so the table of the third
+	// chunk (mapping SID 10 to another field name) is the second table instance
+	// but belongs to the third chunk
+
+	chunks := [][]byte{
+		marshalBinary(t, map[string]interface{}{"alpha": 1}),
+		{0xD3, 0x8A, 0x21, 0x02},
+		marshalBinary(t, map[string]interface{}{"beta": 3})[len(bvm):],
+	}
+
+	var out bytes.Buffer
+	w := NewSymbolWriter(&out, 3)
+	for _, chunk := range chunks {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "$10     \"alpha\" (chunk 3)\n$10     \"beta\" (chunk 5)\n"
+	if got := out.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package ionzst
+
+import (
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The inLocation function converts all timestamps of the value (including the
+/// timestamps nested in structs and lists) to the given location. The precision
+/// is preserved; timestamps without a time component have no offset and are
+/// returned unchanged
+func inLocation(val interface{}, loc *time.Location) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			out[key] = inLocation(field, loc)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = inLocation(elem, loc)
+		}
+		return out
+	case *ion.Timestamp:
+		if v.GetPrecision() <= ion.TimestampPrecisionDay {
+			return v
+		}
+		t := v.GetDateTime().In(loc)
+		kind := ion.TimezoneLocal
+		if _, offset := t.Zone(); offset == 0 {
+			kind = ion.TimezoneUTC
+		}
+		ts := ion.NewTimestampWithFractionalSeconds(t, v.GetPrecision(), kind, v.GetNumberOfFractionalSeconds())
+		return &ts
+	default:
+		return val
+	}
+}
@@ -0,0 +1,95 @@
+package ionzst
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The DumpTrailer function decodes the Sneller specific trailer located between
+/// `start` and the 4-byte offset at the end of the object and writes a
+/// pretty-printed representation to the output stream
+func DumpTrailer(obj io.ReaderAt, start, size int64, out io.Writer) error {
+	data := make([]byte, size-start-4)
+	_, err := obj.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if !bytes.HasPrefix(data, bvm[:]) {
+		data = append(bvm[:], data...)
+	}
+
+	val, err := ion.NewTextDecoder(bytes.NewReader(data)).Decode()
+	if err == ion.ErrNoInput {
+		return fmt.Errorf("empty trailer at offset %d", start)
+	} else if err != nil {
+		return err
+	}
+
+	enc := ion.NewEncoder(ion.NewTextWriterOpts(out, ion.TextWriterPretty))
+	if err = enc.Encode(val); err != nil {
+		return err
+	}
+	if err = enc.Finish(); err != nil {
+		return err
+	}
+
+	// The trailer stores the start offset of every block, the end of a block is
+	// the start of the next one (or the end of the data for the last block)
+
+	trailer, ok := val.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	blocks, ok := trailer["blocks"].([]interface{})
+	if !ok {
+		return nil
+	}
+	end, ok := toInt64(trailer["offset"])
+	if !ok {
+		end = start
+	}
+	fmt.Fprintf(out, "\nblocks: %d\n", len(blocks))
+	for i := range blocks {
+		from, ok := blockOffset(blocks[i])
+		if !ok {
+			fmt.Fprintf(out, "  %d: unknown range\n", i)
+			continue
+		}
+		to := end
+		if i+1 < len(blocks) {
+			if next, ok := blockOffset(blocks[i+1]); ok {
+				to = next
+			}
+		}
+		fmt.Fprintf(out, "  %d: %d-%d (%d bytes)\n", i, from, to, to-from)
+	}
+	return nil
+}
+
+/// The blockOffset function returns the `offset` field of a block descriptor
+func blockOffset(block interface{}) (int64, bool) {
+	desc, ok := block.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	return toInt64(desc["offset"])
+}
+
+/// The toInt64 function converts a decoded ION integer to an int64
+func toInt64(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,88 @@
+package ionzst
+
+import (
+	"io"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The ionTypes array lists the names of the ION types
+var ionTypes = [...]ion.Type{
+	ion.NullType, ion.BoolType, ion.IntType, ion.FloatType, ion.DecimalType,
+	ion.TimestampType, ion.SymbolType, ion.StringType, ion.ClobType,
+	ion.BlobType, ion.ListType, ion.SexpType, ion.StructType,
+}
+
+/// The IsValidType function reports whether the name is the name of an ION
+/// type (e.g. `struct` or `timestamp`)
+func IsValidType(name string) bool {
+	for _, t := range ionTypes {
+		if t.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+/// The typeReader type records the type of the current top-level value and of
+/// its elements, which the `ion.Decoder` does not report (e.g. lists and
+/// s-expressions are both decoded as slices)
+type typeReader struct {
+	ion.Reader
+	depth int
+	typ   ion.Type
+	elems []ion.Type
+}
+
+func (r *typeReader) Next() bool {
+	ok := r.Reader.Next()
+	switch {
+	case ok && r.depth == 0:
+		r.typ, r.elems = r.Reader.Type(), nil
+	case ok && r.depth == 1:
+		r.elems = append(r.elems, r.Reader.Type())
+	}
+	return ok
+}
+
+func (r *typeReader) StepIn() error {
+	err := r.Reader.StepIn()
+	if err == nil {
+		r.depth++
+	}
+	return err
+}
+
+func (r *typeReader) StepOut() error {
+	err := r.Reader.StepOut()
+	if err == nil {
+		r.depth--
+	}
+	return err
+}
+
+/// The typedDecoder type is an `ion.Decoder` which also reports the ION type of
+/// the last decoded value
+type typedDecoder struct {
+	*ion.Decoder
+	r *typeReader
+}
+
+func newTypedDecoder(in io.Reader) *typedDecoder {
+	r := &typeReader{Reader: ion.NewReader(in)}
+	return &typedDecoder{Decoder: ion.NewDecoder(r), r: r}
+}
+
+func (d *typedDecoder) Type() ion.Type {
+	return d.r.typ
+}
+
+/// The hasType function reports whether the type is one of the given type names
+func hasType(t ion.Type, names []string) bool {
+	for _, name := range names {
+		if t.String() == name {
+			return true
+		}
+	}
+	return false
+}
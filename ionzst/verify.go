@@ -0,0 +1,159 @@
+package ionzst
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/amzn/ion-go/ion"
+	"github.com/klauspost/compress/zstd"
+)
+
+/// The Verifier type checks that every chunk passed to Write (as done by the
+/// Extract function) decompresses and that all of its values parse. Chunks are
+/// passed in their original order, a chunk which continues the symbol context of
+/// the preceding chunks is parsed with their symbol tables. Nothing is written,
+/// the first corrupt chunk is reported as error
+type Verifier struct {
+	Chunks int64 // number of verified chunks
+	Values int64 // number of verified top-level values
+
+	MaxSize int64 // maximum size of a decompressed gzip chunk in bytes (0 = no limit)
+
+	dec        *zstd.Decoder
+	compressed bool
+	offset     int64  // offset of the next chunk in the outer container
+	tables     []byte // local symbol tables since the last BVM
+}
+
+/// The NewVerifier function returns a Verifier for compressed (`.ion.zst`) or
+/// uncompressed (`.ion`) chunks
+func NewVerifier(compressed bool, opts ...zstd.DOption) (*Verifier, error) {
+	v := &Verifier{compressed: compressed}
+	if compressed {
+		dec, err := zstd.NewReader(nil, append([]zstd.DOption{zstd.WithDecoderConcurrency(1)}, opts...)...)
+		if err != nil {
+			return nil, err
+		}
+		v.dec = dec
+	}
+	return v, nil
+}
+
+/// The Write method verifies a single chunk
+func (v *Verifier) Write(chunk []byte) (int, error) {
+	index, offset := v.Chunks, v.offset
+	v.Chunks++
+	v.offset += blobSize(len(chunk))
+
+	data := chunk
+	if v.compressed {
+		var err error
+		data, err = decompressChunk(v.dec, int(index), chunk, v.MaxSize)
+		if err != nil {
+			return 0, fmt.Errorf("%w (offset %d)", err, offset)
+		}
+	}
+
+	// Binary chunks without a BVM are prefixed with the symbol tables of the
+	// preceding chunks, unless they bring a table of their own (see Split)
+
+	input := data
+	binary := !looksLikeText(data)
+	if binary && !bytes.HasPrefix(data, bvm[:]) {
+		if isSelfContained(data) {
+			v.tables = v.tables[:0]
+		} else if len(v.tables) > 0 {
+			input = make([]byte, 0, len(bvm)+len(v.tables)+len(data))
+			input = append(append(append(input, bvm[:]...), v.tables...), data...)
+		}
+	}
+
+	r := ion.NewReader(NewBVMReader(bytes.NewReader(input)))
+	for r.Next() {
+		if err := walkValue(r); err != nil {
+			return 0, &ChunkError{Chunk: int(index), Consumed: -1, Err: fmt.Errorf("offset %d, value %d: %w", offset, v.Values, err)}
+		}
+		v.Values++
+	}
+	if err := r.Err(); err != nil {
+		return 0, &ChunkError{Chunk: int(index), Consumed: -1, Err: fmt.Errorf("offset %d, value %d: %w", offset, v.Values, err)}
+	}
+
+	if binary {
+		tables, err := appendSymbolTables(v.tables, data)
+		if err != nil {
+			return 0, &ChunkError{Chunk: int(index), Consumed: -1, Err: fmt.Errorf("offset %d: %w", offset, err)}
+		}
+		v.tables = tables
+	}
+	return len(chunk), nil
+}
+
+/// The Close method releases the zstd decoder
+func (v *Verifier) Close() error {
+	if v.dec != nil {
+		v.dec.Close()
+	}
+	return nil
+}
+
+/// The blobSize function returns the encoded size of a `blob` value holding the
+/// given number of bytes (type descriptor, optional length and data)
+func blobSize(n int) int64 {
+	if n < 14 {
+		return int64(1 + n)
+	}
+	return int64(1 + len(varUint(uint64(n))) + n)
+}
+
+/// The walkValue function reads the current value of the reader, including all
+/// nested values, to make sure it can be decoded
+func walkValue(r ion.Reader) error {
+	if _, err := r.Annotations(); err != nil {
+		return err
+	}
+	if r.IsNull() {
+		return nil
+	}
+
+	var err error
+	switch t := r.Type(); t {
+	case ion.BoolType:
+		_, err = r.BoolValue()
+	case ion.IntType:
+		_, err = r.BigIntValue()
+	case ion.FloatType:
+		_, err = r.FloatValue()
+	case ion.DecimalType:
+		_, err = r.DecimalValue()
+	case ion.TimestampType:
+		_, err = r.TimestampValue()
+	case ion.SymbolType:
+		_, err = r.SymbolValue()
+	case ion.StringType:
+		_, err = r.StringValue()
+	case ion.ClobType, ion.BlobType:
+		_, err = r.ByteValue()
+	case ion.ListType, ion.SexpType, ion.StructType:
+		if err = r.StepIn(); err != nil {
+			return err
+		}
+		for r.Next() {
+			if r.IsInStruct() {
+				if _, err = r.FieldName(); err != nil {
+					return err
+				}
+			}
+			if err = walkValue(r); err != nil {
+				return err
+			}
+		}
+		if err = r.Err(); err != nil {
+			return err
+		}
+		err = r.StepOut()
+	default:
+		err = fmt.Errorf("unexpected ION type %v", t)
+	}
+	return err
+}
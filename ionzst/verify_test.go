@@ -0,0 +1,37 @@
+package ionzst
+
+import (
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestVerifierSymbolContext(t *testing.T) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	// The second chunk has neither a BVM nor a symbol table, its struct
+	// {alpha:2} (D3 8A 21 02) uses the field name SID 10 of the first chunk
+
+	chunks := [][]byte{
+		marshalBinary(t, map[string]interface{}{"alpha": 1}),
+		{0xD3, 0x8A, 0x21, 0x02},
+	}
+
+	v, err := NewVerifier(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	for i, chunk := range chunks {
+		if _, err := v.Write(enc.EncodeAll(chunk, nil)); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+	if v.Chunks != 2 || v.Values != 2 {
+		t.Errorf("verified %d chunks and %d values, want 2 and 2", v.Chunks, v.Values)
+	}
+}
@@ -0,0 +1,85 @@
+package ionzst
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+/// The Predicate type selects structs whose field at the given (dotted) path
+/// equals the given value
+type Predicate struct {
+	Path  []string
+	Value string
+}
+
+/// The ParsePredicate function parses a predicate of the form `field=value`
+func ParsePredicate(spec string) (Predicate, error) {
+	split := strings.IndexByte(spec, '=')
+	if split <= 0 {
+		return Predicate{}, fmt.Errorf("invalid predicate %q (expected field=value)", spec)
+	}
+	return Predicate{Path: strings.Split(spec[:split], "."), Value: spec[split+1:]}, nil
+}
+
+func (p Predicate) String() string {
+	return strings.Join(p.Path, ".") + "=" + p.Value
+}
+
+/// The matches function reports whether the value is a struct satisfying all
+/// predicates. Integers and booleans are compared by value (e.g. `n=042`
+/// matches the integer 42), all other values by their text (see cellText)
+func matches(val interface{}, where []Predicate) bool {
+	record, ok := val.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, p := range where {
+		field, ok := lookupPath(record, p.Path)
+		if !ok || !p.equals(field) {
+			return false
+		}
+	}
+	return true
+}
+
+/// The inRange function reports whether the value is a struct whose field at
+/// the given path is a timestamp within [since, until). Zero times are not
+/// checked; structs missing the field or holding other values are excluded
+func inRange(val interface{}, path []string, since, until time.Time) bool {
+	record, ok := val.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	field, _ := lookupPath(record, path)
+	ts, ok := field.(*ion.Timestamp)
+	if !ok {
+		return false
+	}
+	t := ts.GetDateTime()
+	return (since.IsZero() || !t.Before(since)) && (until.IsZero() || t.Before(until))
+}
+
+/// The equals method compares a single field with the value of the predicate
+func (p Predicate) equals(field interface{}) bool {
+	switch v := field.(type) {
+	case int:
+		n, err := strconv.ParseInt(p.Value, 10, 64)
+		return err == nil && n == int64(v)
+	case int64:
+		n, err := strconv.ParseInt(p.Value, 10, 64)
+		return err == nil && n == v
+	case *big.Int:
+		n, ok := new(big.Int).SetString(p.Value, 10)
+		return ok && n.Cmp(v) == 0
+	case bool:
+		b, err := strconv.ParseBool(p.Value)
+		return err == nil && b == v
+	default:
+		return cellText(v) == p.Value
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+/// The logLevel type is the verbosity of the messages written to stderr. The
+/// output (stdout or `-O`) never holds log messages, whatever the level
+type logLevel int
+
+const (
+	levelQuiet   logLevel = iota // only fatal errors (`--quiet`)
+	levelNormal                  // warnings and notices (default)
+	levelVerbose                 // every chunk and the stage timings (`--verbose`)
+)
+
+// level is the log level selected with `--quiet` or `--verbose`
+var level = levelNormal
+
+// logger writes the log messages to stderr, prefixed with the time of day with
+// `--verbose`
+var logger = log.New(os.Stderr, "", 0)
+
+/// The setLogLevel function selects the log level
+func setLogLevel(l logLevel) {
+	level = l
+	if l == levelVerbose {
+		logger.SetFlags(log.Ltime | log.Lmicroseconds)
+	}
+}
+
+/// The warnf function logs a warning or notice, unless `--quiet` is set
+func warnf(format string, args ...interface{}) {
+	if level >= levelNormal {
+		logger.Printf(format, args...)
+	}
+}
+
+/// The debugf function logs details, only if `--verbose` is set
+func debugf(format string, args ...interface{}) {
+	if level >= levelVerbose {
+		logger.Printf(format, args...)
+	}
+}
+
+/// The chunkLogger type logs the size of every chunk passed to the underlying
+/// writer, i.e. of every decompressed chunk (numbered starting at `first`)
+type chunkLogger struct {
+	w     io.Writer
+	first int
+	n     int64
+}
+
+func (l *chunkLogger) Write(p []byte) (int, error) {
+	index := atomic.AddInt64(&l.n, 1) - 1
+	debugf("chunk %d: %d bytes decompressed", int64(l.first)+index, len(p))
+	return l.w.Write(p)
+}
@@ -1,261 +1,1577 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
 	"context"
-	"encoding/binary"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"iondump/ionzst"
 
-	"github.com/amzn/ion-go/ion"
 	"github.com/klauspost/compress/zstd"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 var (
-	dashe string // -e = endpoint
-	dashf string // -e = filename (bucket & path-to-object)
+	dashe string    // -e = endpoint
+	dashf inputFlag // -f = filename (bucket & path-to-object, or local path), repeatable
+	dasho string    // -o, --format = output format
+	dashO string    // -O = output file
+
+	dashseparator string // --separator = line written between the values of two objects
+
+	dashlimit int // --limit = maximum number of values to dump
+	dashskip  int // --skip = number of values to discard first
+	dashhead  int // --head = dump the first N values (same as --limit)
+	dashtail  int // --tail = dump the last N values
+
+	dashfields  string    // --fields = comma separated list of fields to dump
+	dashwhere   whereFlag // --where = only dump structs with field=value (repeatable)
+	dashtypes   string    // --select-type = comma separated list of ION types to dump
+	dashpretty  bool      // --pretty = indented ION text output
+	dashcolor   string    // --color = highlight ION text (auto, always or never)
+	dashstrict  bool      // --csv-strict = fail on fields missing in the CSV header
+	dashdumphex bool      // --dump-hex = precede values with the hex dump of their encoding
+
+	dashflatten    bool   // --flatten = flatten nested structs and lists into joined keys
+	dashflattensep string // --flatten-separator = separator of the flattened keys
+	dashtz         string // --tz = convert timestamps to the given time zone
+
+	dashtimefield string // --time-field = (dotted) timestamp field filtered by --since/--until
+	dashsince     string // --since = only dump structs with a time field at or after the time
+	dashuntil     string // --until = only dump structs with a time field before the time
+
+	dashsample float64 // --sample = probability with which a value is dumped
+	dashseed   int64   // --seed = seed of the sampling (0 = random)
+
+	dashtrailer bool // --trailer = print the trailer instead of the data
+	dashinfo    bool // --info = print a summary instead of the data
+	dashcount   bool // --count = print the number of values instead of the data
+	dashraw     bool // --raw = same as `--format raw`
+	dashrepack  bool // --repack = write a new `.ion.zst` object
+	dashverify  bool // --verify = check that all chunks decompress and parse
+	dashsymbols bool // --symbols = print the symbol tables instead of the data
+	dashstats   bool // --stats = print per-field type histograms instead of the data
+	dashschema  bool // --schema = print an inferred JSON schema instead of the data
+
+	dashschemasample int64 // --schema-sample = number of values scanned by --schema
+
+	dashoutputperchunk string // --output-per-chunk = directory receiving a file per chunk
+
+	dashchunksize  int // --chunk-size = target chunk size for --repack
+	dashchunk      int // --chunk = only process the chunk with the given index
+	dashstartchunk int // --start-chunk = index of the first chunk to process
+	dashendchunk   int // --end-chunk = index of the last chunk to process
+
+	dashmaxvaluesize int64 // --max-value-size = maximum (decompressed) chunk size
+	dashconcatbvm    bool  // --concat-bvm = insert a BVM before self-contained chunks
+	dashnobvm        bool  // --no-bvm = never prepend a BVM to the input
+
+	dashzstddict string // --zstd-dict = zstd dictionary of the chunks
+
+	dashdecompressonly bool // --decompress-only = write the decompressed chunks exactly as produced
+
+	dashskiperrors bool // --skip-errors = skip corrupt chunks instead of failing
+
+	dashcountbytes bool // --count-bytes = print the compressed and decompressed size of every chunk
+
+	dashprettyindent string // --pretty-indent = indentation width of --pretty and json (or tab)
+	dashjsonbatch    int    // --json-batch = number of values per JSON array (one array per line)
+
+	dashappendnewline bool // --append-newline = terminate every ION text value with a newline right away
+	dashnonewline     bool // --no-newline = separate ION text values by a space instead of newlines
+
+	dashencodersymbols string // --encoder-symbols = symbol tables of the ion-binary format (shared or local)
+
+	dashexplode bool   // --explode = dump the elements of top-level lists as values of their own
+	dashselect  string // --select = path expression of the nested values to dump, e.g. $.events[*].name
+
+	dashsortfields bool // --sort-fields = write the fields of ION structs in alphabetical order
+
+	dashgziplayout string // --gzip-layout = layout of '.gz' objects (auto, container or stream)
+
+	dashmaxchunks int // --max-chunks = abort once more than N chunks are extracted
+
+	dashrecordseparator string // --record-separator = terminator of every value (escapes like \0 allowed)
+
+	dashemitoffsets string // --emit-offsets = file mapping every dumped value to its chunk and offset
+
+	dashcredentialsfile string // --credentials-file = path of the AWS credentials file
+
+	dashquiet   bool // --quiet = suppress all non-fatal messages on stderr
+	dashverbose bool // --verbose = log every chunk and the stage timings to stderr
+
+	dashoffset int64 // --offset = start of the byte range to process
+	dashlength int64 // --length = length of the byte range to process
+
+	dashtraileroffset int64 // --trailer-offset = override the stored trailer offset
+	dashnotrailer     bool  // --no-trailer = treat the whole object as body
+
+	dashprogress   bool          // --progress = report the progress to stderr
+	dashparallel   int           // --parallel = number of decompression workers
+	dashlowmem     bool          // --low-mem = reduce the memory used by the zstd decoders
+	dashbuffersize int           // --buffer-size = size of the read/write buffers
+	dashtimeout    time.Duration // --timeout = abort after the given duration
+	dashretries    int           // --retries = number of retries of failed reads
+
+	dashprofile   string // --profile = AWS credentials profile
+	dashinsecure  bool   // --insecure = use plain HTTP
+	dashanonymous bool   // --anonymous = access public buckets (S3 and GCS) without credentials
+	dashregion    string // --region = S3 region
+	dashpathstyle bool   // --path-style = use path-style bucket addressing
+	dashssekey    string // --sse-key = base64 encoded SSE-C customer key
+
+	dashendpointfromurl bool // --endpoint-from-url = take the endpoint from https://endpoint/bucket/object URLs
+
+	dashmaxidleconns  int  // --max-idle-conns = size of the S3 connection pool
+	dashtlsskipverify bool // --tls-skip-verify = accept any S3 server certificate
+
+	dashindex string // --index = Sneller index whose objects are dumped
+
+	dasharchive string    // --archive = tar or zip archive whose members are dumped
+	dashmember  inputFlag // --member = members of the archive to dump (repeatable, glob patterns)
+
+	dashrecursive  bool // --recursive = include objects below sub-prefixes
+	dashfailfast   bool // --fail-fast = stop at the first object that fails
+	dashwithsource bool // --with-source = tag every value with its object
+
+	dashversion    bool // --version = print the version and exit
+	dashcheck      bool // --check = only check that the objects can be accessed
+	dashjsonerrors bool // --json-errors = report errors as JSON objects
+
+	dashfollow   bool          // --follow = dump the chunks appended to the object
+	dashinterval time.Duration // --interval = poll interval of --follow
 )
 
+// partial is the output file which is removed again on error, so that no
+// truncated output is left behind
+var partial string
+
+// ctx is cancelled on SIGINT or when the `--timeout` expires
+var ctx = context.Background()
+
+// dictionary is the content of the `--zstd-dict` file (nil if not set)
+var dictionary []byte
+
+// skipped is the number of corrupt chunks skipped because of `--skip-errors`
+var skipped int64
+
+// since and until are the time range selected with `--since` and `--until`
+// (zero = open)
+var since, until time.Time
+
+// indent is the indentation of a nesting level selected with `--pretty-indent`
+var indent string
+
+// selector is the path expression given by `--select` (nil if not set)
+var selector *ionzst.Selector
+
+// recordSeparator is the unescaped `--record-separator`
+var recordSeparator string
+
+// offsets writes the `--emit-offsets` file (nil if not set)
+var offsets *ionzst.OffsetWriter
+
+// location is the time zone selected with `--tz` (nil = stored offsets)
+var location *time.Location
+
+// followed is the size of the body (without the trailer) dumped so far by
+// `--follow`, the next poll only dumps the chunks appended after it
+var followed int64
+
+// copyBuf is the buffer of the copy paths (e.g. `--raw`), allocated once with
+// the size given by `--buffer-size` and reused for all objects
+var copyBuf []byte
+
+// dumped is the number of objects whose output has been started, used to
+// write the `--separator` between objects
+var dumped int
+
+/// The exitAccess constant is the exit code of failures to access an object
+/// (e.g. a missing object or denied access), distinguishing them from all other
+/// failures (exit code 1). The flag package already uses exit code 2
+const exitAccess = 3
+
 func exit(err error) {
-	fmt.Fprintln(os.Stderr, err)
+
+	// Errors caused by the cancellation are not always wrapped (e.g. by the ION
+	// reader), so the state of the context takes precedence
+
+	if cerr := ctx.Err(); cerr != nil {
+		err = cerr
+	}
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		msg = "operation timed out"
+	case errors.Is(err, context.Canceled):
+		msg = "interrupted"
+	}
+	if dashjsonerrors {
+		printJSONError(msg, err)
+	} else {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	if partial != "" {
+		os.Remove(partial)
+	}
+	var serr *stageError
+	if errors.As(err, &serr) && serr.stage == "open" {
+		os.Exit(exitAccess)
+	}
 	os.Exit(1)
 }
 
 func init() {
-	flag.StringVar(&dashe, "e", "", "bucket/path-to-object")
-	flag.StringVar(&dashf, "f", "", "endpoint")
+	flag.StringVar(&dashe, "e", "", "endpoint (not required for local files, s3:// paths default to the AWS endpoint)")
+	flag.Var(&dashf, "f", "bucket/path-to-object, gs://bucket/path-to-object, http(s) URL or local file (repeatable, dumped in order)")
+	flag.StringVar(&dashseparator, "separator", "", "line written between the output of two objects (default none)")
+	flag.StringVar(&dasho, "o", "text", formatHelp)
+	flag.StringVar(&dasho, "format", "text", formatHelp)
+	flag.StringVar(&dashO, "O", "", "output file (default stdout)")
+	flag.StringVar(&dashO, "output", "", "output file (default stdout)")
+	flag.IntVar(&dashlimit, "limit", 0, "stop after N values (0 = no limit)")
+	flag.IntVar(&dashskip, "skip", 0, "discard the first N values")
+	flag.IntVar(&dashhead, "head", 0, "dump the first N values (same as --limit)")
+	flag.IntVar(&dashtail, "tail", 0, "dump the last N values (buffered in memory)")
+	flag.BoolVar(&dashpretty, "pretty", false, "write indented multi-line ION text")
+	flag.StringVar(&dashprettyindent, "pretty-indent", "2", "number of spaces per indentation level of --pretty and the json format, or 'tab'")
+	flag.IntVar(&dashjsonbatch, "json-batch", 0, "write a JSON array of up to N values per line instead of a single array (json format only, 0 = single array)")
+	flag.BoolVar(&dashappendnewline, "append-newline", false, "terminate every top-level ION text value with exactly one newline and flush it right away (text format only)")
+	flag.StringVar(&dashrecordseparator, "record-separator", "", "terminate every top-level value of the text and jsonl formats with the given string instead of a newline, escapes \\0, \\n, \\t, \\r, \\\\ and \\xHH are supported (e.g. '\\0' for xargs -0)")
+	flag.BoolVar(&dashnonewline, "no-newline", false, "separate top-level ION text values by a single space instead of newlines (text format only)")
+	flag.StringVar(&dashencodersymbols, "encoder-symbols", "shared", "symbol tables of the ion-binary format: a single shared table (shared) or a local table per value (local)")
+	flag.StringVar(&dashcolor, "color", "auto", "highlight ION text output using ANSI colors (auto = only if stdout is a terminal and NO_COLOR is not set, always or never)")
+	flag.BoolVar(&dashdumphex, "dump-hex", false, "precede every value with its offset, length and binary encoding in hex (text format only)")
+	flag.StringVar(&dashemitoffsets, "emit-offsets", "", "write a tab separated file mapping every dumped value (record number) to its object, chunk and offset in the decompressed chunk, e.g. to build an external index (binary ION only)")
+	flag.BoolVar(&dashexplode, "explode", false, "dump the elements of top-level lists as values of their own, e.g. one JSON document per element (--limit counts elements)")
+	flag.StringVar(&dashselect, "select", "", "dump the nested values matched by the path expression as values of their own, e.g. '$.events[*].name'")
+	flag.BoolVar(&dashsortfields, "sort-fields", false, "write the fields of all (nested) structs of ION text and binary output in alphabetical order, e.g. for reproducible snapshots (json and csv are always sorted)")
+	flag.BoolVar(&dashflatten, "flatten", false, "flatten nested structs and lists into dotted keys, e.g. {\"a.b\":1,\"c.0\":2} (json and jsonl only)")
+	flag.StringVar(&dashflattensep, "flatten-separator", ".", "separator of the keys joined by --flatten")
+	flag.StringVar(&dashtz, "tz", "", "convert timestamps to the given time zone, e.g. UTC or America/New_York (default stored offset)")
+	flag.Float64Var(&dashsample, "sample", 0, "dump every value with the given probability, e.g. 0.01 for about 1% (0 = all values)")
+	flag.Int64Var(&dashseed, "seed", 0, "seed of --sample for reproducible samples (0 = random)")
+	flag.BoolVar(&dashstrict, "csv-strict", false, "fail on fields missing in the CSV header instead of dropping them")
+	flag.StringVar(&dashfields, "fields", "", "comma separated list of (dotted) struct fields to dump")
+	flag.StringVar(&dashtypes, "select-type", "", "only dump top-level values of the given (comma separated) ION types, e.g. struct or list,sexp")
+	flag.StringVar(&dashtimefield, "time-field", "", "(dotted) timestamp field of the structs filtered by --since and --until")
+	flag.StringVar(&dashsince, "since", "", "only dump structs whose --time-field is at or after the given time, e.g. 2024-01-01T00:00:00Z")
+	flag.StringVar(&dashuntil, "until", "", "only dump structs whose --time-field is before the given time, e.g. 2024-02-01T00:00:00Z")
+	flag.Var(&dashwhere, "where", "only dump structs whose (dotted) field equals the value, e.g. 'status=200' (repeatable)")
+	flag.BoolVar(&dashtrailer, "trailer", false, "print the Sneller trailer instead of the data")
+	flag.BoolVar(&dashinfo, "info", false, "print a summary instead of the data")
+	flag.BoolVar(&dashcountbytes, "count-bytes", false, "print the compressed and decompressed size and the compression ratio of every chunk and in total instead of the data")
+	flag.BoolVar(&dashraw, "raw", false, "same as --format raw")
+	flag.BoolVar(&dashdecompressonly, "decompress-only", false, "write the concatenated decompressed chunks exactly as decompressed (no BVM added, unlike --raw)")
+	flag.BoolVar(&dashrepack, "repack", false, "write a new '.ion.zst' object (use with -O)")
+	flag.StringVar(&dashoutputperchunk, "output-per-chunk", "", "write every chunk decompressed to a file of its own in the given directory (out-000.ion, out-001.ion, ...)")
+	flag.IntVar(&dashchunk, "chunk", -1, "only process the chunk with the given (0-based) index")
+	flag.IntVar(&dashstartchunk, "start-chunk", -1, "only process the chunks starting at the given (0-based) index")
+	flag.IntVar(&dashendchunk, "end-chunk", -1, "only process the chunks up to and including the given (0-based) index")
+	flag.BoolVar(&dashskiperrors, "skip-errors", false, "report and skip corrupt chunks, continuing with the next chunk found, instead of failing")
+	flag.IntVar(&dashmaxchunks, "max-chunks", 0, "abort with an error once more than N chunks of an object are extracted (0 = no limit)")
+	flag.Int64Var(&dashmaxvaluesize, "max-value-size", 256<<20, "maximum size of a chunk (compressed and decompressed) in bytes (0 = no limit)")
+	flag.BoolVar(&dashconcatbvm, "concat-bvm", true, "insert a BVM between chunks that bring a symbol table of their own (false = plain concatenation)")
+	flag.BoolVar(&dashnobvm, "no-bvm", false, "never prepend a BVM to the input or the raw output (by default only added if missing)")
+	flag.StringVar(&dashzstddict, "zstd-dict", "", "zstd dictionary file for dictionary-compressed chunks")
+	flag.IntVar(&dashchunksize, "chunk-size", 1<<20, "target decompressed chunk size for --repack")
+	flag.BoolVar(&dashverify, "verify", false, "check that every chunk decompresses and all values parse")
+	flag.BoolVar(&dashsymbols, "symbols", false, "print the local symbol tables instead of the data")
+	flag.BoolVar(&dashstats, "stats", false, "print how often every field of the top-level structs appears, is null and has which ION type instead of the data")
+	flag.BoolVar(&dashschema, "schema", false, "print a JSON schema inferred from the values (union of all fields and types) instead of the data")
+	flag.Int64Var(&dashschemasample, "schema-sample", 0, "number of values scanned by --schema (0 = all)")
+	flag.BoolVar(&dashcount, "count", false, "print the number of values instead of the data (ignores --skip/--limit)")
+	flag.Int64Var(&dashoffset, "offset", 0, "process the object starting at the given byte offset (ignores the trailer)")
+	flag.Int64Var(&dashlength, "length", 0, "process only the given number of bytes (ignores the trailer, 0 = up to the end)")
+	flag.BoolVar(&dashprogress, "progress", false, "report the progress to stderr every second")
+	flag.Int64Var(&dashtraileroffset, "trailer-offset", -1, "use the given trailer offset instead of the one stored in the last 4 bytes")
+	flag.BoolVar(&dashnotrailer, "no-trailer", false, "treat the whole object as body (the object has no trailer)")
+	flag.StringVar(&dashgziplayout, "gzip-layout", "auto", "layout of '.ion.gz' objects: container (blob container of gzip compressed chunks), stream (a single gzip stream of ION data) or auto (detected from the first bytes)")
+	flag.IntVar(&dashparallel, "parallel", runtime.GOMAXPROCS(0), "number of chunks decompressed in parallel")
+	flag.BoolVar(&dashlowmem, "low-mem", false, "reduce the memory used for decompression (implies --parallel 1 unless given)")
+	flag.IntVar(&dashbuffersize, "buffer-size", 1<<20, "size of the read/write buffers in bytes")
+	flag.DurationVar(&dashtimeout, "timeout", 0, "abort after the given duration, e.g. 30s (0 = no timeout)")
+	flag.IntVar(&dashretries, "retries", 3, "number of consecutive retries of failed reads of remote objects (0 = no retries)")
+	flag.StringVar(&dashprofile, "profile", "", "AWS credentials profile (default profile if empty)")
+	flag.StringVar(&dashcredentialsfile, "credentials-file", "", "path of the AWS credentials file (default $AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials)")
+	flag.BoolVar(&dashinsecure, "insecure", false, "connect to the endpoint using plain HTTP")
+	flag.BoolVar(&dashanonymous, "anonymous", false, "access public buckets (S3 and GCS) without credentials")
+	flag.BoolVar(&dashpathstyle, "path-style", false, "use path-style bucket addressing (e.g. for MinIO)")
+	flag.StringVar(&dashindex, "index", "", "dump all objects referenced by the given Sneller index (e.g. s3://bucket/db/<db>/<table>/index) in order, instead of -f")
+	flag.StringVar(&dasharchive, "archive", "", "dump the '.ion.zst' and '.ion' members of the given tar or zip archive (e.g. backup.tar) without extracting it, instead of -f")
+	flag.Var(&dashmember, "member", "member of the --archive to dump, e.g. data/part-000.ion.zst (repeatable, glob patterns like 'data/*.ion.zst' allowed, default all)")
+	flag.BoolVar(&dashrecursive, "recursive", false, "include objects below sub-prefixes (for prefixes ending with '/')")
+	flag.BoolVar(&dashfailfast, "fail-fast", false, "stop at the first object that fails (for prefixes ending with '/')")
+	flag.BoolVar(&dashwithsource, "with-source", false, "tag every value with the path of its object")
+	flag.BoolVar(&dashfollow, "follow", false, "keep polling the object and dump the chunks appended to it until interrupted")
+	flag.DurationVar(&dashinterval, "interval", 5*time.Second, "poll interval of --follow")
+	flag.BoolVar(&dashversion, "version", false, "print the version and exit")
+	flag.BoolVar(&dashcheck, "check", false, "only check that the objects exist and can be accessed, printing their size and modification time (exit code 3 if not)")
+	flag.BoolVar(&dashquiet, "quiet", false, "suppress all non-fatal messages (warnings, skipped chunks, retries) on stderr")
+	flag.BoolVar(&dashverbose, "verbose", false, "log every extracted and decompressed chunk and the stage timings to stderr")
+	flag.BoolVar(&dashjsonerrors, "json-errors", false, "report errors on stderr as JSON objects with the error, the stage and the chunk")
+	flag.StringVar(&dashssekey, "sse-key", "", "base64 encoded 256-bit SSE-C customer key (default $IONDUMP_SSE_KEY)")
+	flag.StringVar(&dashregion, "region", "", "S3 region used for signing (auto-detected if empty)")
+	flag.IntVar(&dashmaxidleconns, "max-idle-conns", 0, "maximum number of idle (keep-alive) connections to the S3 endpoint (0 = client default)")
+	flag.BoolVar(&dashtlsskipverify, "tls-skip-verify", false, "do not verify the TLS certificate of the S3 endpoint (e.g. self-signed MinIO, insecure)")
+	flag.BoolVar(&dashendpointfromurl, "endpoint-from-url", false, "treat http(s)://endpoint/bucket/object URLs as S3 paths of the given endpoint instead of plain HTTP downloads")
 }
 
 func main() {
 
 	flag.Parse()
-	if dashe == "" || dashf == "" {
+	configureFromEnv()
+	if dashquiet && dashverbose {
+		exit(errors.New("--quiet and --verbose cannot be combined"))
+	}
+	switch {
+	case dashquiet:
+		setLogLevel(levelQuiet)
+	case dashverbose:
+		setLogLevel(levelVerbose)
+	}
+	if dashversion || flag.Arg(0) == "version" {
+		printVersion(os.Stdout)
+		return
+	}
+
+	if len(dashf) == 0 && dashindex == "" && dasharchive == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if dashindex != "" {
+		if len(dashf) > 0 {
+			exit(errors.New("--index cannot be combined with -f"))
+		}
+		if dashfollow || dashrepack || dashoutputperchunk != "" {
+			exit(errors.New("--index cannot be combined with --follow, --repack or --output-per-chunk"))
+		}
+	}
+	if dasharchive != "" {
+		if len(dashf) > 0 || dashindex != "" {
+			exit(errors.New("--archive cannot be combined with -f or --index"))
+		}
+		if dashfollow || dashrepack || dashoutputperchunk != "" {
+			exit(errors.New("--archive cannot be combined with --follow, --repack or --output-per-chunk"))
+		}
+	} else if len(dashmember) > 0 {
+		exit(errors.New("--member requires --archive"))
+	}
+
+	// S3 paths without `-e` refer to AWS, unless the endpoint is taken from the
+	// URLs given instead
 
-	bucket, object := s3split(dashf)
-	if bucket == "" {
-		exit(errors.New("no valid bucket specified"))
+	stdin := 0
+	for i, name := range dashf {
+		if dashendpointfromurl && (strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")) {
+			path, err := fromEndpointURL(name)
+			if err != nil {
+				exit(err)
+			}
+			dashf[i], name = path, path
+		}
+		if name == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		exit(errors.New("stdin ('-') can only be read once"))
+	}
+	if dashchunk >= 0 && (dashstartchunk >= 0 || dashendchunk >= 0) {
+		exit(errors.New("--chunk cannot be combined with --start-chunk/--end-chunk"))
+	}
+	if dashendchunk >= 0 && dashstartchunk > dashendchunk {
+		exit(fmt.Errorf("--start-chunk %d is after --end-chunk %d", dashstartchunk, dashendchunk))
+	}
+	if dashrepack && len(dashf) > 1 {
+		exit(errors.New("--repack requires a single input"))
 	}
-	if !strings.HasSuffix(object, ".ion.zst") && !strings.HasSuffix(object, ".10n.zst") {
-		exit(errors.New("no valid '.ion.zst' object specified"))
+	if dashchunksize <= 0 {
+		exit(fmt.Errorf("invalid --chunk-size %d", dashchunksize))
+	}
+	if dashrepack && dashO == "" && isTerminal(os.Stdout) {
+		exit(errors.New("--repack writes a binary object, use -O or redirect stdout"))
+	}
+	if dashfollow {
+		if len(dashf) > 1 || dashf[0] == "-" || isS3Prefix(dashf[0]) {
+			exit(errors.New("--follow requires a single object (not stdin or a prefix)"))
+		}
+		if dashtail > 0 || dashoffset > 0 || dashlength > 0 || dashnotrailer || dashchunk >= 0 || dashstartchunk >= 0 || dashendchunk >= 0 {
+			exit(errors.New("--follow cannot be combined with --tail, --offset/--length, --no-trailer or a chunk selection"))
+		}
+		if dashtrailer || dashrepack || dashverify || dashinfo || dashcount || dashsymbols || dashstats || dashschema || dashoutputperchunk != "" {
+			exit(errors.New("--follow cannot be combined with --trailer, --repack, --verify, --info, --count, --symbols, --stats, --schema or --output-per-chunk"))
+		}
+		if dashinterval <= 0 {
+			exit(fmt.Errorf("invalid --interval %v", dashinterval))
+		}
+	}
+	if dashoutputperchunk != "" {
+		if len(dashf) > 1 {
+			exit(errors.New("--output-per-chunk requires a single input"))
+		}
+		if dashO != "" || dashrepack || dashverify || dashinfo || dashcount || dashsymbols || dashstats || dashschema {
+			exit(errors.New("--output-per-chunk cannot be combined with -O, --repack, --verify, --info, --count, --symbols, --stats or --schema"))
+		}
 	}
 
-	// Initialize S3 client
+	if dashdecompressonly && dasho != "text" {
+		exit(errors.New("--decompress-only cannot be combined with --format"))
+	}
+	if dashraw {
+		dasho = "raw"
+	}
+	if !isValidFormat(dasho) {
+		exit(fmt.Errorf("invalid output format %q (valid formats: %s)", dasho, strings.Join(formats[:], ", ")))
+	}
 
-	home, err := os.UserHomeDir()
+	if dashhead > 0 && dashtail > 0 {
+		exit(errors.New("--head and --tail cannot be combined"))
+	}
+	if dashhead > 0 {
+		dashlimit = dashhead
+	}
+	switch dashcolor {
+	case "auto", "never":
+	case "always":
+		if dasho != "text" {
+			exit(errors.New("--color requires the text output format"))
+		}
+	default:
+		exit(fmt.Errorf("invalid --color %q (expected auto, always or never)", dashcolor))
+	}
+	spaces, err := parseIndent(dashprettyindent)
 	if err != nil {
 		exit(err)
 	}
-	creds := credentials.NewFileAWSCredentials(filepath.Join(home, ".aws", "credentials"), "")
-
-	client, err := minio.New(dashe, &minio.Options{
-		Creds:  creds,
-		Secure: true,
+	indent = spaces
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "pretty-indent" && !(dashpretty && dasho == "text") && (dasho != "json" || dashjsonbatch > 0) {
+			exit(errors.New("--pretty-indent requires --pretty or the json output format (without --json-batch)"))
+		}
 	})
-	if err != nil {
-		exit(err)
+	if dashcountbytes && dashskiperrors {
+		exit(errors.New("--count-bytes cannot be combined with --skip-errors"))
+	}
+	if dashmaxidleconns < 0 {
+		exit(fmt.Errorf("invalid --max-idle-conns %d", dashmaxidleconns))
+	}
+	if dashmaxchunks < 0 {
+		exit(fmt.Errorf("invalid --max-chunks %d", dashmaxchunks))
+	}
+	if dashjsonbatch < 0 {
+		exit(fmt.Errorf("invalid --json-batch %d", dashjsonbatch))
+	}
+	if dashjsonbatch > 0 && dasho != "json" {
+		exit(errors.New("--json-batch requires the json output format"))
+	}
+	if (dashappendnewline || dashnonewline) && dasho != "text" {
+		exit(errors.New("--append-newline and --no-newline require the text output format"))
 	}
+	if dashappendnewline && dashnonewline {
+		exit(errors.New("--append-newline and --no-newline cannot be combined"))
+	}
+	if dashrecordseparator != "" {
+		if dasho != "text" && dasho != "jsonl" {
+			exit(errors.New("--record-separator requires the text or jsonl output format"))
+		}
+		if dashappendnewline || dashnonewline || dashdumphex {
+			exit(errors.New("--record-separator cannot be combined with --append-newline, --no-newline or --dump-hex"))
+		}
+		if recordSeparator, err = unescape(dashrecordseparator); err != nil {
+			exit(fmt.Errorf("invalid --record-separator: %w", err))
+		}
+	}
+	if dashnonewline && dashdumphex {
+		exit(errors.New("--no-newline and --dump-hex cannot be combined"))
+	}
+	if dashencodersymbols != "shared" && dashencodersymbols != "local" {
+		exit(fmt.Errorf("invalid --encoder-symbols %q (valid values: shared, local)", dashencodersymbols))
+	}
+	if dashencodersymbols == "local" && dasho != "ion-binary" {
+		exit(errors.New("--encoder-symbols local requires the ion-binary output format"))
+	}
+	if dashexplode && (dashdumphex || dasho == "raw" || dasho == "ion-binary") {
+		exit(errors.New("--explode cannot be combined with --dump-hex or the raw and ion-binary formats"))
+	}
+	if dashemitoffsets != "" && (dashexplode || dasho == "raw" || dasho == "ion-binary") {
+		exit(errors.New("--emit-offsets cannot be combined with --explode or the raw and ion-binary formats"))
+	}
+	if dashgziplayout != "auto" && dashgziplayout != "container" && dashgziplayout != "stream" {
+		exit(fmt.Errorf("invalid --gzip-layout %q (expected auto, container or stream)", dashgziplayout))
+	}
+	if dashsortfields && (dasho == "raw" || dasho == "ion-binary") {
+		exit(errors.New("--sort-fields cannot be combined with the raw and ion-binary formats"))
+	}
+	if dashselect != "" {
+		if dashdumphex || dasho == "raw" || dasho == "ion-binary" {
+			exit(errors.New("--select cannot be combined with --dump-hex or the raw and ion-binary formats"))
+		}
+		if selector, err = ionzst.ParseSelector(dashselect); err != nil {
+			exit(err)
+		}
+	}
+	if dashdumphex && dasho != "text" {
+		exit(errors.New("--dump-hex requires the text output format"))
+	}
+	if dashflatten && dasho != "json" && dasho != "jsonl" {
+		exit(errors.New("--flatten requires the json or jsonl output format"))
+	}
+	for _, t := range splitList(dashtypes) {
+		if !ionzst.IsValidType(t) {
+			exit(fmt.Errorf("invalid ION type %q", t))
+		}
+	}
+	if dashschemasample < 0 {
+		exit(fmt.Errorf("invalid --schema-sample %d", dashschemasample))
+	}
+	if dashsample < 0 || dashsample > 1 {
+		exit(fmt.Errorf("invalid sample rate %v (expected 0 to 1)", dashsample))
+	}
+	if dashseed == 0 {
+		dashseed = time.Now().UnixNano()
+	}
+	// Every decompression worker holds its own decoder with its buffers, so the
+	// low memory mode only uses a single one unless requested otherwise
+
+	if dashlowmem {
+		parallel := false
+		flag.Visit(func(f *flag.Flag) {
+			parallel = parallel || f.Name == "parallel"
+		})
+		if !parallel {
+			dashparallel = 1
+		}
+	}
+	if dashzstddict != "" {
+		dict, err := os.ReadFile(dashzstddict)
+		if err != nil {
+			exit(err)
+		}
+		dictionary = dict
+	}
+	if dashtz != "" {
+		loc, err := time.LoadLocation(dashtz)
+		if err != nil {
+			exit(fmt.Errorf("invalid time zone %q: %w", dashtz, err))
+		}
+		location = loc
+	}
+	if dashdumphex && dashtail > 0 {
+		exit(errors.New("--dump-hex and --tail cannot be combined"))
+	}
+	if (dashsince != "" || dashuntil != "") && dashtimefield == "" {
+		exit(errors.New("--since and --until require --time-field"))
+	}
+	if dashsince != "" {
+		if since, err = parseTime(dashsince); err != nil {
+			exit(fmt.Errorf("invalid --since: %w", err))
+		}
+	}
+	if dashuntil != "" {
+		if until, err = parseTime(dashuntil); err != nil {
+			exit(fmt.Errorf("invalid --until: %w", err))
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		exit(fmt.Errorf("empty time range (--since %s is not before --until %s)", dashsince, dashuntil))
+	}
+
+	// SIGINT cancels all requests in flight, a second SIGINT terminates the
+	// process immediately
+
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	go func(done <-chan struct{}) {
+		<-done
+		stop()
+	}(ctx.Done())
+	if dashtimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dashtimeout)
+		defer cancel()
+	}
+
+	// Prepare output
+
+	var out io.Writer = os.Stdout
+	if dashO != "" {
+		f, err := os.Create(dashO)
+		if err != nil {
+			exit(err)
+		}
+		partial = dashO
+		w := bufio.NewWriter(f)
+		out = w
+		defer func() {
+			err := w.Flush()
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				exit(err)
+			}
+		}()
+	}
+
+	// The offsets of all objects are written to a single file, the record
+	// numbers continue across objects like the records of the output
+
+	if dashemitoffsets != "" {
+		f, err := os.Create(dashemitoffsets)
+		if err != nil {
+			exit(err)
+		}
+		w := bufio.NewWriter(f)
+		offsets = ionzst.NewOffsetWriter(w)
+		defer func() {
+			err := w.Flush()
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				exit(err)
+			}
+		}()
+	}
+
+	// Multiple inputs are dumped one after the other, every object has its own
+	// trailer and symbol context. A S3 path ending with a slash refers to all
+	// objects below that prefix
+
+	if dashindex != "" {
+		if err := dumpIndex(dashindex, out); err != nil {
+			exit(err)
+		}
+	}
+	if dasharchive != "" {
+		var err error
+		if archive, err = openArchive(ctx, dasharchive); err != nil {
+			exit(withStage("open", err))
+		}
+		defer archive.Close()
+		if err := dumpArchive(out); err != nil {
+			exit(err)
+		}
+	}
+	for _, name := range dashf {
+		var err error
+		switch {
+		case dashcheck:
+			err = check(name, out)
+		case dashfollow:
+			err = follow(name, out)
+		case isS3Prefix(name):
+			err = dumpPrefix(name, out)
+		default:
+			err = dumpObject(name, out)
+		}
+		if err != nil {
+			if len(dashf) > 1 {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+			exit(err)
+		}
+	}
+	if n := atomic.LoadInt64(&skipped); n > 0 {
+		warnf("%d corrupt chunks skipped", n)
+	}
+}
+
+/// The dumpObject function processes a single object according to the flags and
+/// writes the result to the output stream
+func dumpObject(name string, out io.Writer) error {
+	if name != "-" && !hasValidSuffix(objectPath(name)) {
+		return errors.New("no valid '.ion.zst' or '.ion' object specified")
+	}
+	compressed := name == "-" || isCompressed(objectPath(name))
+
+	if dashseparator != "" && dumped > 0 && !dashfollow {
+		if _, err := fmt.Fprintln(out, dashseparator); err != nil {
+			return err
+		}
+	}
+	dumped++
+
+	start := time.Now()
+	defer func() {
+		debugf("%s: done after %v", redactURL(name), time.Since(start))
+	}()
 
 	// Prepare object stream
 
-	obj, err := client.GetObject(context.Background(), bucket, object, minio.GetObjectOptions{})
+	obj, err := open(ctx, name)
 	if err != nil {
-		exit(err)
+		return withStage("open", err)
 	}
 	defer obj.Close()
 
-	size, err := sizeWithoutTrailer(obj)
+	size, err := obj.Stat()
 	if err != nil {
-		exit(err)
+		return withStage("open", err)
 	}
+	debugf("%s: opened after %v, %d bytes", redactURL(name), time.Since(start), size)
+
+	// Gzip compressed objects either use the blob container (with gzip instead
+	// of zstd compressed chunks) or are a single gzip stream of ION data, which
+	// has neither trailer nor chunks
 
-	inputWithoutTrailer := &io.LimitedReader{R: obj, N: size}
-	inputWithBVM := newBVMReader(inputWithoutTrailer)
+	gzipStream := false
+	if name != "-" && isGzip(objectPath(name)) {
+		if gzipStream, err = isGzipStream(obj); err != nil {
+			return withStage("open", err)
+		}
+	}
+	if gzipStream && (dashverify || dashoutputperchunk != "" || dashcountbytes || dashfollow || dashchunk >= 0 || dashstartchunk >= 0 || dashendchunk >= 0) {
+		return errors.New("a single gzip stream has no chunks, --verify, --output-per-chunk, --count-bytes, --follow and the chunk selection are not supported")
+	}
+
+	var (
+		bodySize            int64
+		inputWithoutTrailer io.Reader
+	)
+
+	// An explicit byte range bypasses the trailer, it is read using random access
+	// reads (buffered, since every read may be a separate request)
+
+	if dashoffset > 0 || dashlength > 0 {
+		warnf("warning: --offset/--length ignore the trailer, chunk boundaries may be violated and the output may be partial")
+		if dashoffset < 0 || dashoffset > size {
+			return fmt.Errorf("offset %d outside of object (%d bytes)", dashoffset, size)
+		}
+		bodySize = size - dashoffset
+		if dashlength > 0 && dashlength < bodySize {
+			bodySize = dashlength
+		}
+		section := io.NewSectionReader(obj, dashoffset, bodySize)
+		inputWithoutTrailer = &ctxReader{ctx: ctx, r: bufio.NewReaderSize(section, dashbuffersize)}
+	} else {
+
+		// The trailer offset stored in the last 4 bytes can be overridden to
+		// salvage the data of objects with a damaged trailer
+
+		switch {
+		case dashnotrailer || gzipStream:
+			bodySize = size
+		case dashtraileroffset >= 0:
+			bodySize = size - dashtraileroffset - 4
+			if bodySize < 0 {
+				return fmt.Errorf("trailer offset %d exceeds object size %d", dashtraileroffset, size)
+			}
+		default:
+			bodySize, err = ionzst.SizeWithoutTrailer(obj)
+			if err != nil {
+				return withStage("trailer", err)
+			}
+			debugf("%s: trailer read after %v, %d bytes without trailer", redactURL(name), time.Since(start), bodySize)
+		}
+
+		if dashtrailer {
+			if dashnotrailer {
+				return errors.New("--trailer cannot be combined with --no-trailer")
+			}
+			return withStage("trailer", ionzst.DumpTrailer(obj, bodySize, size, out))
+		}
+
+		// Remote objects are opened again where the last read stopped if the
+		// transfer fails with a transient error
+
+		if dashfollow && followed > bodySize {
+			warnf("warning: object shrank from %d to %d bytes (without trailer), dumping it from the start", followed, bodySize)
+			followed = 0
+		}
+		if dashfollow && followed > 0 {
+			section := io.NewSectionReader(obj, followed, bodySize-followed)
+			inputWithoutTrailer = &ctxReader{ctx: ctx, r: bufio.NewReaderSize(section, dashbuffersize)}
+		} else {
+			stream, err := openRetrying(ctx, obj, dashretries)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+
+			buffered := bufio.NewReaderSize(stream, dashbuffersize)
+			inputWithoutTrailer = &io.LimitedReader{R: &ctxReader{ctx: ctx, r: buffered}, N: bodySize}
+		}
+		followed = bodySize
+	}
+	read := &readCounter{r: inputWithoutTrailer}
+
+	// A BVM is only prepended if the input does not start with one already,
+	// `--no-bvm` passes the input on unchanged
+
+	var inputWithBVM io.Reader = read
+	if !dashnobvm {
+		inputWithBVM = ionzst.NewBVMReader(read)
+	}
+
+	// Every chunk is decompressed on its own and written to a separate file, so
+	// the files can be processed in parallel downstream
+
+	if dashoutputperchunk != "" {
+		return withStage("extract", extractChunkFiles(inputWithBVM, compressed, dashoutputperchunk))
+	}
+
+	// Verification walks every chunk on its own, so that the first corrupt chunk
+	// can be reported with its index and offset
+
+	if dashverify {
+		v, err := ionzst.NewVerifier(compressed, decoderOptions()...)
+		if err != nil {
+			return err
+		}
+		defer v.Close()
+		v.MaxSize = dashmaxvaluesize
+		if err := extract(inputWithBVM, v); err != nil {
+			return withStage("verify", err)
+		}
+		_, err = fmt.Fprintf(out, "OK: %d chunks, %d values\n", v.Chunks, v.Values)
+		return err
+	}
 
 	// Process
 
-	compReader, compWriter := io.Pipe()
+	// Both ends of the pipe are buffered to avoid many small reads and writes,
+	// the writing end is flushed before it is closed
+
 	decompReader, decompWriter := io.Pipe()
+	bufferedReader := bufio.NewReaderSize(decompReader, dashbuffersize)
+	bufferedWriter := bufio.NewWriterSize(decompWriter, dashbuffersize)
+	chunks := &counter{}
+
+	// Only the dump stage counts the decoded values, the other modes (e.g.
+	// `--count` or `--stats`) report the bytes read and chunks extracted only
+
+	var values *int64
+	if dashprogress {
+		dumping := !dashinfo && !dashcountbytes && !dashcount && !dashsymbols && !dashstats && !dashschema && !dashdecompressonly && dasho != "raw" && !dashrepack
+		p := startProgress(read, chunks, dumping)
+		defer p.stop()
+		values = p.values
+	}
+
+	// The pipeline goroutines never terminate the process themselves: they close
+	// their end of the pipe (passing the error downstream) and report the error,
+	// which is handled once all of them have returned
 
 	var wg sync.WaitGroup
-	wg.Add(3)
+	errc := make(chan error, 2) // one slot per pipeline goroutine
 
-	go func() {
-		defer wg.Done()
-		defer compWriter.Close()
-		err := extract(inputWithBVM, compWriter)
-		if err != nil {
-			exit(err)
+	wait := func(err error) error {
+		wg.Wait()
+		close(errc)
+		if perr, ok := <-errc; ok {
+			err = perr
 		}
-	}()
-	go func() {
-		defer wg.Done()
-		defer decompWriter.Close()
-		err := decompress(compReader, decompWriter)
+		if err == io.ErrClosedPipe {
+			return nil
+		}
+		return err
+	}
+
+	// Every chunk is an independent zstd frame, so chunks can be decompressed in
+	// parallel as long as they are reassembled in their original order
+
+	// Chunks with a symbol table of their own are separated by a BVM, so that
+	// their symbol IDs cannot resolve against the tables of previous chunks
+
+	var decompressed io.Writer = bufferedWriter
+	if dashconcatbvm && !dashdecompressonly {
+		decompressed = ionzst.NewChunkFramer(bufferedWriter)
+	}
+
+	// Every chunk is written at once on either side of the decompression, so
+	// `--count-bytes` records the size of every write
+
+	var compressedSizes, decompressedSizes sizeRecorder
+	record := func(sizes *sizeRecorder, w io.Writer) io.Writer {
+		if !dashcountbytes {
+			return w
+		}
+		sizes.w = w
+		return sizes
+	}
+	decompressed = record(&decompressedSizes, decompressed)
+
+	// The chunk index maps the offsets of `--emit-offsets` back to the chunks
+
+	var chunkIndex *ionzst.ChunkIndex
+	if offsets != nil {
+		chunkIndex = ionzst.NewChunkIndex(decompressed, extractor().First, dashconcatbvm && !dashdecompressonly)
+		decompressed = chunkIndex
+	}
+
+	// `--symbols` lists the symbol tables chunk by chunk, nothing is passed on
+	// to the later stages. A single gzip stream has no chunks, its symbol tables
+	// are listed from the decompressed stream
+
+	var symbols *ionzst.SymbolWriter
+	if dashsymbols && !gzipStream {
+		symbols = ionzst.NewSymbolWriter(out, extractor().First)
+		decompressed = symbols
+	}
+	if compressed && level >= levelVerbose {
+		decompressed = &chunkLogger{w: decompressed, first: extractor().First}
+	}
+
+	if gzipStream {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := withStage("decompress", gunzipStream(read, bufferedWriter))
+			if err == nil {
+				err = bufferedWriter.Flush()
+			}
+			decompWriter.CloseWithError(err)
+			if err != nil && err != io.ErrClosedPipe {
+				errc <- err
+			}
+		}()
+	} else if compressed {
+		dec, err := ionzst.NewParallelDecompressor(decompressed, dashparallel, decoderOptions()...)
 		if err != nil {
-			exit(err)
+			return err
 		}
-	}()
+		dec.MaxSize = dashmaxvaluesize
+		if dashskiperrors {
+			dec.Skip = func(err error) {
+				skipChunk(err)
+				if chunkIndex != nil {
+					chunkIndex.Skip()
+				}
+				if symbols != nil {
+					symbols.Skip()
+				}
+			}
+		}
+		chunks.w = record(&compressedSizes, &stageWriter{stage: "decompress", w: dec})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := withStage("extract", extract(inputWithBVM, chunks))
+			debugf("%s: %d chunks extracted after %v", redactURL(name), chunks.writes, time.Since(start))
+			if cerr := dec.Close(); err == nil {
+				err = withStage("decompress", cerr)
+			}
+			debugf("%s: decompressed after %v", redactURL(name), time.Since(start))
+			if err == nil {
+				err = bufferedWriter.Flush()
+			}
+			decompWriter.CloseWithError(err)
+			if err != nil && err != io.ErrClosedPipe {
+				errc <- err
+			}
+		}()
+	} else {
+		chunks.w = record(&compressedSizes, decompressed)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := withStage("extract", extract(inputWithBVM, chunks))
+			debugf("%s: %d chunks extracted after %v", redactURL(name), chunks.writes, time.Since(start))
+			if err == nil {
+				err = bufferedWriter.Flush()
+			}
+			decompWriter.CloseWithError(err)
+			if err != nil && err != io.ErrClosedPipe {
+				errc <- err
+			}
+		}()
+	}
+
+	if dashinfo {
+		decompressed, err := copyBuffered(io.Discard, bufferedReader)
+		decompReader.CloseWithError(err)
+		if err := wait(withStage("info", err)); err != nil {
+			return err
+		}
+		summary := info{size: size, bodySize: bodySize, chunks: chunks.writes, decompressed: decompressed}
+		summary.print(out)
+		return nil
+	}
+
+	if dashcountbytes {
+		_, err := copyBuffered(io.Discard, bufferedReader)
+		decompReader.CloseWithError(err)
+		if err := wait(withStage("count-bytes", err)); err != nil {
+			return err
+		}
+		return printSizes(out, extractor().First, bodySize, compressedSizes.sizes, decompressedSizes.sizes)
+	}
+
+	if dashcount {
+		n, err := ionzst.Count(bufferedReader)
+		decompReader.CloseWithError(err)
+		if err := wait(withStage("count", err)); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, n)
+		return err
+	}
+
+	if dashsymbols {
+		var err error
+		if symbols == nil {
+			err = ionzst.Symbols(bufferedReader, out)
+		} else {
+			_, err = copyBuffered(io.Discard, bufferedReader)
+		}
+		decompReader.CloseWithError(err)
+		return wait(withStage("symbols", err))
+	}
+
+	if dashstats {
+		err := ionzst.Stats(bufferedReader, out)
+		decompReader.CloseWithError(err)
+		return wait(withStage("stats", err))
+	}
+
+	// Scanning a sample stops the pipeline early, like `--limit`
+
+	if dashschema {
+		err := ionzst.Schema(bufferedReader, out, dashschemasample)
+		decompReader.CloseWithError(err)
+		return wait(withStage("schema", err))
+	}
+
+	// The decompressed chunks are written exactly as the decompressor produced
+	// them, e.g. to compare them byte by byte with the output of other tools
+
+	if dashdecompressonly {
+		_, err := copyBuffered(out, bufferedReader)
+		decompReader.CloseWithError(err)
+		return wait(withStage("decompress", err))
+	}
+
+	// The decompressed chunks are written as is, only the leading BVM is added if
+	// missing (the BVMs of subsequent chunks are kept to reset the symbol tables)
+
+	if dasho == "raw" {
+		var raw io.Reader = bufferedReader
+		if !dashnobvm {
+			raw = ionzst.NewBVMReader(bufferedReader)
+		}
+		_, err := copyBuffered(out, raw)
+		decompReader.CloseWithError(err)
+		return wait(withStage("raw", err))
+	}
+
+	if dashrepack {
+		err := ionzst.Repack(bufferedReader, out, dashchunksize)
+		decompReader.CloseWithError(err)
+		return wait(withStage("repack", err))
+	}
+
+	var source string
+	if dashwithsource {
+		source = name
+	}
+
+	// The dump stage may stop early (e.g. `--limit`), closing its input signals
+	// the upstream stages to stop as well
+
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := dump(decompReader, os.Stdout)
+		opts := ionzst.DumpOptions{
+			Format: dasho,
+			Limit:  dashlimit,
+			Skip:   dashskip,
+			Tail:   dashtail,
+			Pretty: dashpretty,
+			Indent: indent,
+			Batch:  dashjsonbatch,
+			Color:  useColor(),
+			Fields: splitList(dashfields),
+			Strict: dashstrict,
+			Where:  dashwhere,
+			Hex:    dashdumphex,
+			Values: values,
+			Source: source,
+		}
+		if dashflatten {
+			opts.Flatten = dashflattensep
+		}
+		opts.Location = location
+		opts.Sample, opts.Seed = dashsample, dashseed
+		opts.Types = splitList(dashtypes)
+		opts.Symbols = dashencodersymbols
+		opts.Explode = dashexplode
+		opts.Select = selector
+		opts.SortFields = dashsortfields
+		switch {
+		case dashappendnewline:
+			opts.Separator = "newline"
+		case dashnonewline:
+			opts.Separator = "none"
+		}
+		opts.RecordSeparator = recordSeparator
+		if offsets != nil {
+			opts.Offsets, opts.Chunks, opts.Object = offsets, chunkIndex, name
+		}
+		if dashtimefield != "" {
+			opts.TimeField = strings.Split(dashtimefield, ".")
+			opts.Since, opts.Until = since, until
+		}
+		err := ionzst.Dump(bufferedReader, out, opts)
+		decompReader.CloseWithError(err)
 		if err != nil {
-			exit(err)
+			errc <- withStage("dump", err)
 		}
 	}()
 
-	wg.Wait()
+	return wait(nil)
 }
 
 // --
 
-/// The s3split function splits a S3 path into `bucket` and `object` portions
-func s3split(name string) (string, string) {
-	out := strings.TrimPrefix(name, "s3://")
-	split := strings.IndexByte(out, '/')
-	if split == -1 || split == len(out) {
-		exit(fmt.Errorf("invalid s3 path spec %q", out))
+/// The formats array lists the supported output formats. `raw` writes the
+/// decompressed chunks as is, all other formats are implemented by `ionzst.Dump`
+var formats = [...]string{"text", "json", "jsonl", "csv", "raw", "ion", "ion-binary"}
+
+/// The formatHelp constant is the usage of `-o`, which tells the two binary ION
+/// formats apart
+const formatHelp = "output format (" + "text, json, jsonl, csv, raw = the decompressed data as is, " +
+	"ion = binary ION re-encoded from the decoded values like text (all filters apply), " +
+	"ion-binary = binary ION copied value by value, keeping annotations and all types (only --skip/--limit apply))"
+
+/// The isValidFormat function reports whether the given output format is
+/// supported
+func isValidFormat(format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
 	}
-	bucket := out[:split]
-	object := out[split+1:]
-	return bucket, object
+	return false
 }
 
-/// The sizeWithoutTrailer function returns the size of the requested object
-/// excluding the size of the Sneller specific trailer and offset
-func sizeWithoutTrailer(obj *minio.Object) (int64, error) {
-
-	// The Sneller 'ion.zst' format contains a trailer and a 4-byte offset pointing
-	// to the beginning of this trailer
+var suffixes = [...]string{".ion.zst", ".10n.zst", ".ion.gz", ".10n.gz", ".ion", ".10n"}
 
-	stat, err := obj.Stat()
-	if err != nil {
-		return -1, err
+/// The hasValidSuffix function reports whether the given path has one of the
+/// supported file extensions
+func hasValidSuffix(name string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
 	}
+	return false
+}
+
+/// The isCompressed function reports whether the given path refers to a zstd
+/// or gzip compressed object
+func isCompressed(name string) bool {
+	return strings.HasSuffix(name, ".zst") || isGzip(name)
+}
 
-	data := make([]byte, 4)
+/// The isGzip function reports whether the given path refers to a gzip
+/// compressed object
+func isGzip(name string) bool {
+	return strings.HasSuffix(name, ".gz")
+}
 
-	_, err = obj.ReadAt(data, stat.Size-4)
+/// The isGzipStream function reports whether a gzip compressed object is a
+/// single gzip stream of ION data (without blob container and trailer) rather
+/// than a blob container of gzip compressed chunks, as selected by
+/// `--gzip-layout` or detected from the first bytes of the object
+func isGzipStream(obj object) (bool, error) {
+	switch dashgziplayout {
+	case "stream":
+		return true, nil
+	case "container":
+		return false, nil
+	}
+	head := make([]byte, 2)
+	n, err := obj.ReadAt(head, 0)
 	if err != nil && err != io.EOF {
-		return -1, err
+		return false, err
 	}
+	return ionzst.IsGzip(head[:n]), nil
+}
 
-	pos, err := obj.Seek(0, 0)
-	if err != nil || pos != 0 {
-		return -1, err
+/// The gunzipStream function decompresses a single gzip stream of ION data and
+/// writes it to the output stream, prepending a BVM if missing (unless
+/// `--no-bvm` is given)
+func gunzipStream(in io.Reader, out io.Writer) error {
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
 	}
+	defer zr.Close()
+	var r io.Reader = zr
+	if !dashnobvm {
+		r = ionzst.NewBVMReader(zr)
+	}
+	_, err = io.Copy(out, r)
+	return err
+}
 
-	offset := binary.LittleEndian.Uint32(data)
-
-	return stat.Size - int64(offset) - 4, nil
+/// The dumpPrefix function dumps all objects below the given S3 prefix in
+/// sequence (see dumpObjects)
+func dumpPrefix(prefix string, out io.Writer) error {
+	names, err := listS3(ctx, s3Endpoint(), prefix, dashrecursive)
+	if err != nil {
+		return err
+	}
+	return dumpObjects(names, out)
 }
 
-/// The extract function extracts all ION data chunks from the outer ION
-//  container and writes them to the output stream
-func extract(in io.Reader, out io.Writer) error {
+/// The dumpIndex function dumps all objects referenced by the given Sneller
+/// index in the order of the index (or checks them with `--check`). The paths of
+/// the index are relative to the root of the bucket (or of the local directory
+/// containing the `db` directory)
+func dumpIndex(name string, out io.Writer) error {
+	obj, err := open(ctx, name)
+	if err != nil {
+		return withStage("open", err)
+	}
+	defer obj.Close()
+	r, err := obj.Open(ctx)
+	if err != nil {
+		return withStage("open", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return withStage("index", err)
+	}
+	paths, err := ionzst.IndexPaths(data, decoderOptions()...)
+	if err != nil {
+		return withStage("index", fmt.Errorf("%s: %w", name, err))
+	}
 
-	// The Sneller 'ion.zst' format stores multiple chunks of ION data in `blob`
-	// values of the outer ION container
+	// Only the data objects are dumped, other objects referenced by the index
+	// (e.g. the parts of an indirect index) are not supported
 
-	r := ion.NewReader(in)
-	for r.Next() {
-		t := r.Type()
-		if t != ion.BlobType {
-			return errors.New("unexpected token type")
+	root := indexRoot(name)
+	var names []string
+	for _, path := range paths {
+		if hasValidSuffix(path) {
+			names = append(names, root+path)
 		}
-		val, err := r.ByteValue()
-		if err != nil {
-			return err
+	}
+	if skipped := len(paths) - len(names); skipped > 0 {
+		warnf("warning: %d objects referenced by the index are not '.ion.zst' or '.ion' objects and are skipped", skipped)
+	}
+	if dashcheck {
+		for _, name := range names {
+			if err := checkObject(name, out); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
 		}
-		_, err = out.Write(val)
-		if err != nil {
-			return err
+		return nil
+	}
+	return dumpObjects(names, out)
+}
+
+/// The indexRoot function returns the prefix of the paths referenced by the
+/// given index: the bucket of S3 paths, otherwise the location of the `db`
+/// directory (or the directory of the index)
+func indexRoot(name string) string {
+	switch {
+	case strings.HasPrefix(name, "s3://"):
+		bucket, _, _ := s3split(name)
+		return "s3://" + bucket + "/"
+	case !isLocal(name) && !strings.Contains(name, "://"):
+		bucket, _, _ := s3split(name)
+		return bucket + "/"
+	}
+	switch i := strings.Index(name, "/db/"); {
+	case i >= 0:
+		return name[:i+1]
+	case strings.HasPrefix(name, "db/"):
+		return ""
+	}
+	return name[:strings.LastIndexByte(name, '/')+1]
+}
+
+/// The dumpObjects function dumps the given objects in sequence. Failing objects
+/// are reported with their name and skipped unless `--fail-fast` is given
+func dumpObjects(names []string, out io.Writer) error {
+	failed := 0
+	for _, name := range names {
+		if err := dumpObject(name, out); err != nil {
+			if dashfailfast || ctx.Err() != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			warnf("%s: %v", name, err)
+			failed++
 		}
 	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d objects failed", failed, len(names))
+	}
 	return nil
 }
 
-/// The decompress function decompresses the given input data and writes the
-/// resulting bytes to the output stream
-func decompress(in io.Reader, out io.Writer) error {
-	dec, err := zstd.NewReader(in)
-	if err != nil {
+/// The extract function extracts either all chunks or only the ones selected
+/// with `--chunk` or `--start-chunk`/`--end-chunk`, rejecting chunks larger than
+/// `--max-value-size`. Every chunk is logged with `--verbose`
+func extract(in io.Reader, out io.Writer) error {
+	return extractor().ExtractChunks(in, func(index int, chunk []byte) error {
+		debugf("chunk %d: %d bytes extracted", index, len(chunk))
+		_, err := out.Write(chunk)
 		return err
+	})
+}
+
+/// The copyBuffered function copies the input to the output. The shared buffer
+/// is only used if neither side provides a fast path (io.WriterTo or
+/// io.ReaderFrom, e.g. of a bufio.Reader or a file)
+func copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	if copyBuf == nil {
+		copyBuf = make([]byte, dashbuffersize)
 	}
-	defer dec.Close()
-	_, err = io.Copy(out, dec)
+	return io.CopyBuffer(dst, src, copyBuf)
+}
+
+/// The extractChunkFiles function writes every extracted chunk decompressed to
+/// a file of its own in the given directory, named after the chunk index (e.g.
+/// `out-000.ion`). Every file is standalone binary ION
+func extractChunkFiles(in io.Reader, compressed bool, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	s, err := ionzst.NewChunkSplitter(compressed, decoderOptions()...)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer s.Close()
+	s.MaxSize = dashmaxvaluesize
+	return extractor().ExtractChunks(in, func(index int, chunk []byte) error {
+		data, err := s.Split(index, chunk)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, fmt.Sprintf("out-%03d.ion", index)), data, 0o644)
+	})
 }
 
-/// The dump function reads ION data from the given input and writes an
-/// equivalent textual representation to the output stream
-func dump(in io.Reader, out io.Writer) error {
-	dec := ion.NewTextDecoder(in)
-	enc := ion.NewTextEncoder(out)
-
-	for {
-		val, err := dec.Decode()
-		if err == ion.ErrNoInput {
-			break
-		} else if err != nil {
-			return err
+/// The extractor function returns the Extractor configured by the flags
+func extractor() ionzst.Extractor {
+	e := ionzst.Extractor{MaxSize: dashmaxvaluesize, MaxChunks: dashmaxchunks, DecoderOptions: decoderOptions()}
+	if dashskiperrors {
+		e.Skip = skipChunk
+	}
+	switch {
+	case dashchunk >= 0:
+		e.First, e.Count = dashchunk, 1
+	case dashstartchunk >= 0 || dashendchunk >= 0:
+		if dashstartchunk > 0 {
+			e.First = dashstartchunk
 		}
-		if err = enc.Encode(val); err != nil {
-			return err
+		if dashendchunk >= 0 {
+			e.Count = dashendchunk - e.First + 1
 		}
 	}
-	if err := enc.Finish(); err != nil {
-		return err
+	return e
+}
+
+/// The skipChunk function reports a chunk skipped because of `--skip-errors`,
+/// the error includes the index of the chunk
+func skipChunk(err error) {
+	atomic.AddInt64(&skipped, 1)
+	warnf("skipping corrupt %v", err)
+}
+
+/// The useColor function reports whether the ION text output is highlighted:
+/// always or never if requested explicitly, otherwise only when writing to a
+/// terminal and the `NO_COLOR` environment variable is not set
+func useColor() bool {
+	switch dashcolor {
+	case "always":
+		return true
+	case "never":
+		return false
 	}
-	return nil
+	if dashO != "" || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stdout)
 }
 
-// ---
+/// The isTerminal function reports whether the file is a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
 
-var bvm = [...]byte{0xE0, 0x01, 0x00, 0xEA}
+/// The decoderOptions function returns the options of all zstd decoders: the
+/// `--max-value-size` limit, the `--zstd-dict` dictionary and `--low-mem`
+func decoderOptions() []zstd.DOption {
+	var opts []zstd.DOption
+	if dashlowmem {
+		opts = append(opts, zstd.WithDecoderLowmem(true))
+	}
+	if dashmaxvaluesize > 0 {
+		opts = append(opts, zstd.WithDecoderMaxMemory(uint64(dashmaxvaluesize)))
+	}
+	if dictionary != nil {
+		opts = append(opts, zstd.WithDecoderDicts(dictionary))
+	}
+	return opts
+}
 
-type bvmReader struct {
-	r io.Reader
-	n int
+/// The splitList function splits a comma separated list, ignoring empty entries
+func splitList(list string) []string {
+	var out []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
 
-func (r *bvmReader) Read(p []byte) (n int, err error) {
+/// The parseTime function parses the time given by `--since` or `--until`:
+/// either a RFC 3339 timestamp or a date (midnight UTC)
+func parseTime(spec string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", spec); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, spec)
+}
 
-	// The Sneller 'ion.zst' format does not prepend the ION BVM. We have to add it to
-	// allow the `ion.TextDecoder` to detect binary input format
+/// The parseIndent function returns the indentation given by `--pretty-indent`:
+/// a number of spaces or `tab`
+func parseIndent(spec string) (string, error) {
+	if spec == "tab" {
+		return "\t", nil
+	}
+	n, err := strconv.Atoi(spec)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid --pretty-indent %q (expected a number of spaces or tab)", spec)
+	}
+	return strings.Repeat(" ", n), nil
+}
 
-	if r.n < 4 {
-		n := len(p)
-		if n > 4-r.n {
-			n = 4 - r.n
+/// The unescape function replaces the escape sequences `\0`, `\n`, `\t`, `\r`,
+/// `\\` and `\xHH` (a byte in hex) of the given string
+func unescape(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
 		}
-		r.n += copy(p, bvm[r.n:r.n+n])
-		if n == len(p) {
-			return n, nil
+		if i++; i == len(s) {
+			return "", errors.New("incomplete escape sequence at the end")
 		}
-		nr, err := r.r.Read(p[n:])
-		if err != nil {
-			return 0, err
+		switch s[i] {
+		case '0':
+			b.WriteByte(0)
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return "", errors.New("incomplete escape sequence \\x")
+			}
+			n, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape sequence \\x%s", s[i+1:i+3])
+			}
+			b.WriteByte(byte(n))
+			i += 2
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+/// The configureFromEnv function takes the endpoint and the object from the
+/// environment if `-e` and `-f` are not given on the command line (e.g. in
+/// containers): `IONDUMP_ENDPOINT` or `S3_ENDPOINT` and `IONDUMP_OBJECT` or
+/// `S3_OBJECT`. The flags take precedence, even if empty (e.g. `-e ""` to refer
+/// to local files)
+func configureFromEnv() {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if !set["e"] {
+		dashe = getenv("IONDUMP_ENDPOINT", "S3_ENDPOINT")
+	}
+	if len(dashf) == 0 && dashindex == "" && dasharchive == "" {
+		if name := getenv("IONDUMP_OBJECT", "S3_OBJECT"); name != "" {
+			dashf.Set(name)
 		}
-		return n + nr, nil
+	}
+}
 
+/// The getenv function returns the value of the first of the given environment
+/// variables that is set and not empty
+func getenv(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
 	}
+	return ""
+}
 
-	return r.r.Read(p)
+/// The inputFlag type collects the inputs of all `-f` flags
+type inputFlag []string
+
+func (f *inputFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *inputFlag) Set(name string) error {
+	*f = append(*f, name)
+	return nil
 }
 
-func newBVMReader(input io.Reader) *bvmReader {
-	return &bvmReader{r: input, n: 0}
+/// The whereFlag type collects the predicates of all `--where` flags
+type whereFlag []ionzst.Predicate
+
+func (w *whereFlag) String() string {
+	list := make([]string, len(*w))
+	for i, p := range *w {
+		list[i] = p.String()
+	}
+	return strings.Join(list, ",")
+}
+
+func (w *whereFlag) Set(spec string) error {
+	p, err := ionzst.ParsePredicate(spec)
+	if err != nil {
+		return err
+	}
+	*w = append(*w, p)
+	return nil
+}
+
+/// The ctxReader type stops reading once the context is done, which also ends
+/// the processing of sources that do not observe the context (e.g. local files)
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
 }
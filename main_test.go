@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"iondump/ionzst"
+
+	"github.com/amzn/ion-go/ion"
+)
+
+// withStdin replaces stdin by a file holding the given data while f runs
+func withStdin(t *testing.T, data []byte, f func()) {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	stdin := os.Stdin
+	os.Stdin = in
+	defer func() { os.Stdin = stdin }()
+	f()
+}
+
+func TestDumpObjectStdin(t *testing.T) {
+
+	// The `.ion.zst` object is built in memory and read from stdin, which is
+	// served as memObject
+
+	var raw bytes.Buffer
+	enc := ion.NewEncoder(ion.NewBinaryWriter(&raw))
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(map[string]interface{}{"id": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	var obj bytes.Buffer
+	if err := ionzst.Repack(&raw, &obj, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	format := dasho
+	dasho = "jsonl"
+	defer func() { dasho = format }()
+
+	var out bytes.Buffer
+	withStdin(t, obj.Bytes(), func() {
+		if err := dumpObject("-", &out); err != nil {
+			t.Fatalf("dumpObject: %v", err)
+		}
+	})
+	if got, want := out.String(), "{\"id\":0}\n{\"id\":1}\n{\"id\":2}\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+/// The readCounter type counts the bytes read from the underlying reader
+type readCounter struct {
+	r io.Reader
+	n int64
+}
+
+func (c *readCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+/// The progress type periodically reports the bytes read, the chunks extracted
+/// and the values decoded to stderr (never to stdout, which may hold the output)
+type progress struct {
+	read   *readCounter
+	chunks *counter
+	values *int64 // nil if the values are not counted
+	done   chan struct{}
+	exited chan struct{}
+}
+
+/// The startProgress function starts reporting the progress every second. The
+/// decoded values are only reported if countValues is set (see `values`)
+func startProgress(read *readCounter, chunks *counter, countValues bool) *progress {
+	p := &progress{read: read, chunks: chunks, done: make(chan struct{}), exited: make(chan struct{})}
+	if countValues {
+		p.values = new(int64)
+	}
+	go func() {
+		defer close(p.exited)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+/// The stop method ends the periodic reports and prints the final summary
+func (p *progress) stop() {
+	close(p.done)
+	<-p.exited
+	p.print()
+	fmt.Fprintln(os.Stderr)
+}
+
+/// The print method overwrites the current line of stderr with the counts
+func (p *progress) print() {
+	fmt.Fprintf(os.Stderr, "\r%d bytes read, %d chunks", atomic.LoadInt64(&p.read.n), atomic.LoadInt64(&p.chunks.writes))
+	if p.values != nil {
+		fmt.Fprintf(os.Stderr, ", %d values", atomic.LoadInt64(p.values))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+/// The rangeOpener interface is implemented by remote objects which can be read
+/// starting at an arbitrary offset
+type rangeOpener interface {
+	openAt(ctx context.Context, off int64) (io.ReadCloser, error)
+}
+
+/// The retryReader type reads an object sequentially. If a read fails with a
+/// transient error, the object is opened again starting at the offset of the
+/// first byte that has not been read yet, so the error is invisible to the
+/// reader
+type retryReader struct {
+	ctx     context.Context
+	obj     rangeOpener
+	r       io.ReadCloser
+	off     int64 // number of bytes read successfully
+	retries int   // maximum number of consecutive retries
+	failed  int   // number of consecutive failed attempts
+	err     error // error to retry on the next read
+}
+
+/// The openRetrying function opens the object for sequential reads, retrying
+/// transient errors up to `retries` times in a row. Objects which cannot be
+/// opened at an offset are opened without retries
+func openRetrying(ctx context.Context, obj object, retries int) (io.ReadCloser, error) {
+	ro, ok := obj.(rangeOpener)
+	if !ok || retries <= 0 {
+		return obj.Open(ctx)
+	}
+	r := &retryReader{ctx: ctx, obj: ro, retries: retries}
+	if err := r.reopen(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *retryReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		if err := r.reopen(r.err); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.r.Read(p)
+	r.off += int64(n)
+	if n > 0 {
+		r.failed = 0
+	}
+	if err != nil && err != io.EOF && isTransient(err) {
+
+		// Bytes read along with the error are still valid, the retry is
+		// deferred to the next call
+
+		r.err = err
+		if n > 0 {
+			return n, nil
+		}
+		return r.Read(p)
+	}
+	return n, err
+}
+
+/// The reopen method opens the object again at the current offset. Transient
+/// errors are retried with exponential backoff (starting at 100ms); `cause` is
+/// the error which triggered the retry (nil when opening the object initially)
+func (r *retryReader) reopen(cause error) error {
+	if r.r != nil {
+		r.r.Close()
+		r.r = nil
+	}
+	err := cause
+	for {
+		if err != nil {
+			if r.failed >= r.retries {
+				return fmt.Errorf("giving up after %d retries: %w", r.retries, err)
+			}
+			delay := 100 * time.Millisecond << r.failed
+			r.failed++
+			warnf("%v (retrying at offset %d in %v)", err, r.off, delay)
+			timer := time.NewTimer(delay)
+			select {
+			case <-r.ctx.Done():
+				timer.Stop()
+				return r.ctx.Err()
+			case <-timer.C:
+			}
+		}
+		r.r, err = r.obj.openAt(r.ctx, r.off)
+		if err == nil {
+			r.err = nil
+			return nil
+		}
+		if !isTransient(err) {
+			return err
+		}
+	}
+}
+
+func (r *retryReader) Close() error {
+	if r.r == nil {
+		return nil
+	}
+	return r.r.Close()
+}
+
+/// The isTransient function reports whether a failed request may succeed when
+/// it is repeated, i.e. the error is a network error or a server error (5xx).
+/// Errors caused by the cancellation of the context are never transient
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if resp := minio.ToErrorResponse(err); resp.StatusCode >= http.StatusInternalServerError {
+		return true
+	}
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= http.StatusInternalServerError
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"iondump/ionzst"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+/// The object interface is implemented by local files, S3 objects, HTTP objects
+/// (e.g. GCS) and buffered stdin or HTTP responses
+type object interface {
+	ionzst.ObjectSource
+	io.Closer
+}
+
+/// The s3split function splits a S3 path (`bucket/object`, optionally prefixed
+/// with `s3://`) into `bucket` and `object` portions at the first slash, so
+/// bucket names may contain dots. The object is empty for paths ending right
+/// after the bucket (`bucket/`), which refer to the whole bucket
+func s3split(name string) (string, string, error) {
+	path := strings.TrimPrefix(name, "s3://")
+	split := strings.IndexByte(path, '/')
+	if split == -1 {
+		return "", "", fmt.Errorf("invalid S3 path %q: missing object (or a trailing slash for the whole bucket)", name)
+	}
+	bucket, object := path[:split], path[split+1:]
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 path %q: missing bucket", name)
+	}
+	return bucket, object, nil
+}
+
+// urlEndpoint is the endpoint of the URLs given with `--endpoint-from-url`
+var urlEndpoint string
+
+/// The s3Endpoint function returns the endpoint of S3 requests: the `-e`
+/// endpoint, the endpoint of the URLs given with `--endpoint-from-url` or else
+/// the AWS endpoint (of the `--region` if given)
+func s3Endpoint() string {
+	switch {
+	case dashe != "":
+		return dashe
+	case urlEndpoint != "":
+		return urlEndpoint
+	case dashregion != "":
+		return "s3." + dashregion + ".amazonaws.com"
+	}
+	return "s3.amazonaws.com"
+}
+
+/// The fromEndpointURL function converts a path-style URL of a S3 object
+/// (`https://endpoint/bucket/object`) into a S3 path and records its endpoint.
+/// All URLs have to refer to the same endpoint
+func fromEndpointURL(name string) (string, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return "", err
+	}
+	if u.RawQuery != "" {
+		return "", fmt.Errorf("%s: URLs with a query (e.g. presigned URLs) cannot be used with --endpoint-from-url", redactURL(name))
+	}
+	endpoint := u.Scheme + "://" + u.Host
+	if urlEndpoint != "" && urlEndpoint != endpoint {
+		return "", fmt.Errorf("all URLs have to refer to the same endpoint (%s and %s)", urlEndpoint, endpoint)
+	}
+	urlEndpoint = endpoint
+	return "s3://" + strings.TrimPrefix(u.Path, "/"), nil
+}
+
+/// The isLocal function reports whether the given path refers to a local file
+/// rather than a remote object. Paths without a scheme refer to S3 objects if an
+/// endpoint is given
+func isLocal(name string) bool {
+	if strings.HasPrefix(name, "file://") {
+		return true
+	}
+	if strings.Contains(name, "://") {
+		return false
+	}
+	return dashe == ""
+}
+
+/// The objectPath function returns the path of the object referred to by the
+/// given name, i.e. the name without the query of HTTP(S) URLs (e.g. the
+/// signature of a presigned URL)
+func objectPath(name string) string {
+	if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+		return name
+	}
+	u, err := url.Parse(name)
+	if err != nil {
+		return name
+	}
+	return u.Path
+}
+
+/// The isS3Prefix function reports whether the given path refers to all S3
+/// objects below a prefix rather than a single object
+func isS3Prefix(name string) bool {
+	if name == "-" || isLocal(name) || strings.HasPrefix(name, "gs://") ||
+		strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		return false
+	}
+	return strings.HasSuffix(name, "/")
+}
+
+/// The open function opens the object referred to by the given name, which is
+/// either a member of the `--archive`, `-` (stdin), a local path, a HTTP(S) URL,
+/// a GCS path or a S3 path. The context is used for all requests of remote
+/// objects
+func open(ctx context.Context, name string) (object, error) {
+	switch {
+	case archive != nil:
+		return archive.open(name)
+	case name == "-":
+		return openStdin()
+	case strings.HasPrefix(name, "http://"), strings.HasPrefix(name, "https://"):
+		return openURL(ctx, name)
+	case strings.HasPrefix(name, "gs://"):
+		return openGCS(ctx, name)
+	case isLocal(name):
+		return openFile(strings.TrimPrefix(name, "file://"))
+	default:
+		return openS3(ctx, s3Endpoint(), name)
+	}
+}
+
+// --
+
+/// The memObject type serves an in-memory buffer as an object
+type memObject struct {
+	*bytes.Reader
+}
+
+/// The openStdin function reads the whole standard input into memory
+func openStdin() (object, error) {
+
+	// The trailer offset is located at the end of the object, so a non-seekable
+	// stream has to be buffered completely before it can be processed
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("no input on stdin")
+	}
+	return memObject{bytes.NewReader(data)}, nil
+}
+
+func (o memObject) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(o.Reader, 0, o.Size())), nil
+}
+
+func (o memObject) Stat() (int64, error) {
+	return o.Size(), nil
+}
+
+func (memObject) Close() error {
+	return nil
+}
+
+// --
+
+/// The fileObject type serves a local file as an object
+type fileObject struct {
+	*os.File
+	size  int64
+	mtime time.Time
+}
+
+/// The openFile function opens a local file
+func openFile(name string) (object, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileObject{File: f, size: info.Size(), mtime: info.ModTime()}, nil
+}
+
+func (o *fileObject) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(o.File, 0, o.size)), nil
+}
+
+func (o *fileObject) Stat() (int64, error) {
+	return o.size, nil
+}
+
+func (o *fileObject) ModTime() time.Time {
+	return o.mtime
+}
+
+// --
+
+/// The s3Object type serves a S3 object
+type s3Object struct {
+	client *minio.Client
+	bucket string
+	name   string
+	size   int64
+	mtime  time.Time // last modification of the object
+
+	ctx  context.Context        // context of random access reads
+	opts minio.GetObjectOptions // options of all requests (e.g. SSE-C key)
+}
+
+/// The openS3 function opens a S3 object
+func openS3(ctx context.Context, endpoint, name string) (object, error) {
+	bucket, name, err := s3split(name)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("no S3 object specified")
+	}
+
+	client, err := newS3Client(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects encrypted with a customer key (SSE-C) require the key for every
+	// request, including the metadata request
+
+	var opts minio.GetObjectOptions
+	if opts.ServerSideEncryption, err = newSSE(); err != nil {
+		return nil, err
+	}
+
+	stat, err := client.StatObject(ctx, bucket, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{client: client, bucket: bucket, name: name, size: stat.Size, mtime: stat.LastModified, ctx: ctx, opts: opts}, nil
+}
+
+/// The listS3 function returns the paths of all objects with a supported file
+/// extension below the given prefix (`bucket/prefix/`)
+func listS3(ctx context.Context, endpoint, prefix string, recursive bool) ([]string, error) {
+	bucket, prefix, err := s3split(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newS3Client(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: recursive}
+	for info := range client.ListObjects(ctx, bucket, opts) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		if hasValidSuffix(info.Key) {
+			names = append(names, bucket+"/"+info.Key)
+		}
+	}
+	return names, nil
+}
+
+/// The newS3Client function initializes the S3 client for the given endpoint
+func newS3Client(endpoint string) (*minio.Client, error) {
+	creds, err := newCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	// The endpoint may also be given as URL of a custom (S3 compatible) service,
+	// an `http://` URL implies --insecure
+
+	secure := !dashinsecure
+	switch {
+	case strings.HasPrefix(endpoint, "http://"):
+		endpoint, secure = strings.TrimPrefix(endpoint, "http://"), false
+	case strings.HasPrefix(endpoint, "https://"):
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	// Virtual-host-style addressing requires DNS entries for every bucket, which
+	// MinIO deployments usually lack. Buckets with dots in their name are
+	// addressed path-style over HTTPS by the client anyway, since they do not
+	// match the wildcard certificate of the endpoint
+
+	lookup := minio.BucketLookupAuto
+	if dashpathstyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	transport, err := newTransport(secure)
+	if err != nil {
+		return nil, err
+	}
+
+	return minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Transport:    transport,
+		Region:       dashregion,
+		BucketLookup: lookup,
+	})
+}
+
+/// The newTransport function returns the HTTP transport of the S3 client tuned
+/// by `--max-idle-conns` and `--tls-skip-verify`, or nil (the default transport
+/// of the client) if neither is given
+func newTransport(secure bool) (http.RoundTripper, error) {
+	if dashmaxidleconns == 0 && !dashtlsskipverify {
+		return nil, nil
+	}
+	tr, err := minio.DefaultTransport(secure)
+	if err != nil {
+		return nil, err
+	}
+
+	// All connections go to the same endpoint, so the pool is not limited per
+	// host either
+
+	if dashmaxidleconns > 0 {
+		tr.MaxIdleConns = dashmaxidleconns
+		tr.MaxIdleConnsPerHost = dashmaxidleconns
+	}
+	if dashtlsskipverify {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		tr.TLSClientConfig.InsecureSkipVerify = true
+	}
+	return tr, nil
+}
+
+func (o *s3Object) Open(ctx context.Context) (io.ReadCloser, error) {
+	obj, err := o.client.GetObject(ctx, o.bucket, o.name, o.opts)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (o *s3Object) openAt(ctx context.Context, off int64) (io.ReadCloser, error) {
+	opts := o.opts
+	if off > 0 {
+		if err := opts.SetRange(off, 0); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := o.client.GetObject(ctx, o.bucket, o.name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (o *s3Object) Stat() (int64, error) {
+	return o.size, nil
+}
+
+func (o *s3Object) ModTime() time.Time {
+	return o.mtime
+}
+
+/// The ReadAt method requests only the given byte range of the object, so that
+/// reading the trailer does not transfer the body of the object
+func (o *s3Object) ReadAt(p []byte, off int64) (int, error) {
+	if off >= o.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > o.size {
+		end = o.size
+	}
+	opts := o.opts
+	if err := opts.SetRange(off, end-1); err != nil {
+		return 0, err
+	}
+	body, err := o.client.GetObject(o.ctx, o.bucket, o.name, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:end-off])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (o *s3Object) Close() error {
+	return nil
+}
+
+/// The newSSE function returns the server-side encryption settings for objects
+/// encrypted with a customer key. The base64 encoded 256-bit key is taken from
+/// `--sse-key` or the `IONDUMP_SSE_KEY` environment variable (nil if neither is
+/// set)
+func newSSE() (encrypt.ServerSide, error) {
+	key := dashssekey
+	if key == "" {
+		key = os.Getenv("IONDUMP_SSE_KEY")
+	}
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSE-C key: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("invalid SSE-C key: expected 32 bytes, got %d", len(raw))
+	}
+	return encrypt.NewSSEC(raw)
+}
+
+/// The newCredentials function returns the credentials used to access S3. The
+/// sources are tried in order: environment variables (`AWS_ACCESS_KEY_ID`,
+/// `AWS_SECRET_ACCESS_KEY`, `AWS_SESSION_TOKEN`), the AWS credentials file
+/// (see credentialsFile, using the `--profile` profile), AWS SSO and role
+/// assumption settings of the profile in the AWS config file and finally web
+/// identity tokens (`AWS_WEB_IDENTITY_TOKEN_FILE`) or the IAM role of the
+/// instance or task. With `--anonymous` no credentials are used at all
+func newCredentials() (*credentials.Credentials, error) {
+	if dashanonymous {
+		return credentials.NewStaticV4("", "", ""), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	providers := []credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{Filename: credentialsFile(home), Profile: dashprofile},
+	}
+	config, err := newConfigProvider(home)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		providers = append(providers, config)
+	}
+	providers = append(providers, &credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}})
+	return credentials.NewChainCredentials(providers), nil
+}
+
+/// The credentialsFile function returns the path of the AWS credentials file:
+/// `--credentials-file`, the `AWS_SHARED_CREDENTIALS_FILE` environment variable
+/// or `~/.aws/credentials` (in this order)
+func credentialsFile(home string) string {
+	if dashcredentialsfile != "" {
+		return dashcredentialsfile
+	}
+	if filename := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); filename != "" {
+		return filename
+	}
+	return filepath.Join(home, ".aws", "credentials")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestS3Split(t *testing.T) {
+	tests := []struct {
+		name   string
+		bucket string
+		object string
+		err    bool
+	}{
+		{name: "bucket/object.ion.zst", bucket: "bucket", object: "object.ion.zst"},
+		{name: "bucket/dir/object.ion.zst", bucket: "bucket", object: "dir/object.ion.zst"},
+		{name: "my.bucket/object.ion.zst", bucket: "my.bucket", object: "object.ion.zst"},
+		{name: "s3://bucket/dir/object.ion.zst", bucket: "bucket", object: "dir/object.ion.zst"},
+		{name: "bucket/", bucket: "bucket", object: ""},
+		{name: "s3://bucket/", bucket: "bucket", object: ""},
+		{name: "bucket", err: true},
+		{name: "s3://bucket", err: true},
+		{name: "/object.ion.zst", err: true},
+		{name: "s3:///object.ion.zst", err: true},
+		{name: "", err: true},
+	}
+	for _, test := range tests {
+		bucket, object, err := s3split(test.name)
+		if test.err {
+			if err == nil {
+				t.Errorf("s3split(%q) = %q, %q, want an error", test.name, bucket, object)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("s3split(%q): unexpected error %v", test.name, err)
+			continue
+		}
+		if bucket != test.bucket || object != test.object {
+			t.Errorf("s3split(%q) = %q, %q, want %q, %q", test.name, bucket, object, test.bucket, test.object)
+		}
+	}
+}
+
+func TestCredentialsProfile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "credentials")
+	data := []byte(`[default]
+aws_access_key_id = DEFAULTKEY
+aws_secret_access_key = defaultsecret
+
+[other]
+aws_access_key_id = OTHERKEY
+aws_secret_access_key = othersecret
+`)
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the environment nor an AWS config file may provide credentials
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	file, profile := dashcredentialsfile, dashprofile
+	defer func() { dashcredentialsfile, dashprofile = file, profile }()
+	dashcredentialsfile = filename
+
+	for _, test := range []struct {
+		profile string
+		key     string
+		secret  string
+	}{
+		{"", "DEFAULTKEY", "defaultsecret"},
+		{"other", "OTHERKEY", "othersecret"},
+	} {
+		dashprofile = test.profile
+		creds, err := newCredentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := creds.Get()
+		if err != nil {
+			t.Fatalf("profile %q: %v", test.profile, err)
+		}
+		if value.AccessKeyID != test.key || value.SecretAccessKey != test.secret {
+			t.Errorf("profile %q: got %s/%s, want %s/%s", test.profile, value.AccessKeyID, value.SecretAccessKey, test.key, test.secret)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// The build information is set at link time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version = ""
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+/// The printVersion function writes the version, git commit and build date. If
+/// no version was set at link time, the module version is used (e.g. when
+/// installed using `go install`)
+func printVersion(out io.Writer) {
+	v := version
+	if v == "" {
+		v = "(devel)"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+			v = info.Main.Version
+		}
+	}
+	fmt.Fprintf(out, "iondump %s (commit %s, built %s)\n", v, commit, date)
+}